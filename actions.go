@@ -41,13 +41,21 @@ func decodeAction(m map[string]interface{}) Action {
 		messageInfo, ok := m["message"].(map[string]interface{})
 		if ok {
 			res.Body, _ = messageInfo["text"].(string)
+			res.Mentions = decodeActionMentions(messageInfo)
 		}
+		decodeActionReply(res, m)
+		res.EphemeralTTL = parseEphemeralTTL(m["ephemeral_ttl_mode"])
+		res.Expiration = ephemeralExpiration(res.EphemeralTTL, res.ActionTime())
 		rawAttach, _ := m["blob_attachments"].([]interface{})
 		for _, x := range rawAttach {
 			if x, ok := x.(map[string]interface{}); ok {
 				res.Attachments = append(res.Attachments, decodeBlobAttachment(x))
 			}
 		}
+		extensible, ok := m["extensible_attachment"].(map[string]interface{})
+		if ok {
+			res.Attachments = append(res.Attachments, decodeExtensibleAttachment(extensible))
+		}
 		sticker, ok := m["sticker"].(map[string]interface{})
 		if ok {
 			sticker, err := decodeThreadStickerAttachment(sticker)
@@ -121,4 +129,72 @@ type MessageAction struct {
 
 	Body        string
 	Attachments []Attachment
+
+	// Mentions contains the users mentioned in the body.
+	Mentions []Mention
+
+	// RepliedToMessageID is the ID of the message to which
+	// this message replies.
+	// It is "" if the message is not a reply.
+	RepliedToMessageID string
+
+	// RepliedToSnippet is the body of the message to which
+	// this message replies, if it is available.
+	RepliedToSnippet string
+
+	// EphemeralTTL is the lifetime of a disappearing
+	// message.
+	// It is NoEphemeralTTL for a normal message.
+	EphemeralTTL EphemeralTTL
+
+	// Expiration is the time when a disappearing message
+	// will disappear.
+	// It is the zero time for a normal message.
+	Expiration time.Time
+}
+
+// decodeActionMentions decodes the mention ranges from a
+// message node in a thread.
+func decodeActionMentions(messageInfo map[string]interface{}) []Mention {
+	var obj struct {
+		Ranges []struct {
+			Entity struct {
+				ID string `json:"id"`
+			} `json:"entity"`
+			Offset int `json:"offset"`
+			Length int `json:"length"`
+		} `json:"ranges"`
+	}
+	if putJSONIntoObject(messageInfo, &obj) != nil {
+		return nil
+	}
+	var res []Mention
+	for _, r := range obj.Ranges {
+		res = append(res, Mention{
+			FBID:   r.Entity.ID,
+			Offset: r.Offset,
+			Length: r.Length,
+		})
+	}
+	return res
+}
+
+// decodeActionReply fills in the reply information for a
+// message node in a thread.
+func decodeActionReply(res *MessageAction, m map[string]interface{}) {
+	var obj struct {
+		RepliedTo *struct {
+			Message struct {
+				MessageID string `json:"message_id"`
+				Message   struct {
+					Text string `json:"text"`
+				} `json:"message"`
+			} `json:"message"`
+		} `json:"replied_to_message"`
+	}
+	if putJSONIntoObject(m, &obj) != nil || obj.RepliedTo == nil {
+		return
+	}
+	res.RepliedToMessageID = obj.RepliedTo.Message.MessageID
+	res.RepliedToSnippet = obj.RepliedTo.Message.Message.Text
 }
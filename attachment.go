@@ -2,8 +2,10 @@ package fbmsgr
 
 import (
 	"errors"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 // These are attachment type IDs used by Messenger.
@@ -14,6 +16,8 @@ const (
 	StickerAttachmentType       = "sticker"
 	FileAttachmentType          = "file"
 	VideoAttachmentType         = "video"
+	LocationAttachmentType      = "location"
+	ShareAttachmentType         = "share"
 )
 
 const (
@@ -22,6 +26,9 @@ const (
 	blobAnimatedImageAttachmentType = "MessageAnimatedImage"
 	blobFileAttachmentType          = "MessageFile"
 	blobVideoAttachmentType         = "MessageVideo"
+
+	extensibleLocationType     = "MessageLocation"
+	extensibleLiveLocationType = "MessageLiveLocation"
 )
 
 // An Attachment is an abstract non-textual entity
@@ -44,6 +51,11 @@ func decodeAttachment(raw map[string]interface{}) Attachment {
 	if _, ok := raw["mercury"]; !ok {
 		raw = map[string]interface{}{"mercury": raw}
 	}
+	if mercury, ok := raw["mercury"].(map[string]interface{}); ok {
+		if ext, ok := mercury["extensible_attachment"].(map[string]interface{}); ok {
+			return decodeExtensibleAttachment(ext)
+		}
+	}
 
 	audio, err := decodeAudioAttachment(raw)
 	if err == nil {
@@ -65,6 +77,14 @@ func decodeAttachment(raw map[string]interface{}) Attachment {
 	if err == nil {
 		return video
 	}
+	location, err := decodeLocationAttachment(raw)
+	if err == nil {
+		return location
+	}
+	share, err := decodeShareAttachment(raw)
+	if err == nil {
+		return share
+	}
 
 	var typeObj struct {
 		Mercury struct {
@@ -99,6 +119,9 @@ func decodeBlobAttachment(raw map[string]interface{}) Attachment {
 	if err == nil {
 		return video
 	}
+	if _, ok := raw["story_attachment"]; ok {
+		return decodeExtensibleAttachment(raw)
+	}
 
 	var typeObj struct {
 		TypeName string `json:"__typename"`
@@ -111,6 +134,34 @@ func decodeBlobAttachment(raw map[string]interface{}) Attachment {
 	}
 }
 
+// decodeExtensibleAttachment decodes an
+// extensible_attachment, attempting to use one of the
+// built-in Attachment structs if possible.
+func decodeExtensibleAttachment(raw map[string]interface{}) Attachment {
+	location, err := decodeExtensibleLocationAttachment(raw)
+	if err == nil {
+		return location
+	}
+	share, err := decodeExtensibleShareAttachment(raw)
+	if err == nil {
+		return share
+	}
+
+	var typeObj struct {
+		Story struct {
+			Target struct {
+				TypeName string `json:"__typename"`
+			} `json:"target"`
+		} `json:"story_attachment"`
+	}
+	putJSONIntoObject(raw, &typeObj)
+
+	return &UnknownAttachment{
+		Type:    typeObj.Story.Target.TypeName,
+		RawData: raw,
+	}
+}
+
 // An UnknownAttachment is an Attachment of an unknown or
 // unsupported type.
 type UnknownAttachment struct {
@@ -608,3 +659,242 @@ type imageField struct {
 type uriField struct {
 	URI string `json:"uri"`
 }
+
+// A LocationAttachment is an attachment for a shared
+// location.
+type LocationAttachment struct {
+	Latitude  float64
+	Longitude float64
+
+	// Name is the title of the location, such as a place
+	// name or "Pinned Location".
+	Name string
+
+	// Address is a description of the location, if one is
+	// available.
+	Address string
+
+	// Current is true if the location is the sender's
+	// current location, rather than a pin they placed.
+	Current bool
+
+	// Live is true for a live location, which is updated
+	// as the sender moves.
+	Live bool
+
+	MapURL   string
+	ImageURL string
+}
+
+func decodeLocationAttachment(raw map[string]interface{}) (*LocationAttachment, error) {
+	var obj struct {
+		Mercury struct {
+			Type  string `json:"attach_type"`
+			Share struct {
+				URI         string `json:"uri"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+				Media       struct {
+					Image string `json:"image"`
+				} `json:"media"`
+				Target struct {
+					Coordinates *struct {
+						Latitude  float64 `json:"latitude"`
+						Longitude float64 `json:"longitude"`
+					} `json:"coordinates"`
+					Current bool `json:"is_current_location"`
+				} `json:"target"`
+			} `json:"share"`
+		} `json:"mercury"`
+	}
+	if err := putJSONIntoObject(raw, &obj); err != nil {
+		return nil, err
+	}
+	share := obj.Mercury.Share
+	if share.Target.Coordinates == nil {
+		return nil, errors.New("not a location")
+	}
+	return &LocationAttachment{
+		Latitude:  share.Target.Coordinates.Latitude,
+		Longitude: share.Target.Coordinates.Longitude,
+		Name:      share.Title,
+		Address:   share.Description,
+		Current:   share.Target.Current,
+		MapURL:    share.URI,
+		ImageURL:  share.Media.Image,
+	}, nil
+}
+
+func decodeExtensibleLocationAttachment(raw map[string]interface{}) (*LocationAttachment,
+	error) {
+	var obj struct {
+		Story struct {
+			URL   string `json:"url"`
+			Title struct {
+				Text string `json:"text"`
+			} `json:"title_with_entities"`
+			Description struct {
+				Text string `json:"text"`
+			} `json:"description"`
+			Media struct {
+				Image uriField `json:"image"`
+			} `json:"media"`
+			Target struct {
+				TypeName   string `json:"__typename"`
+				Coordinate struct {
+					Latitude  float64 `json:"latitude"`
+					Longitude float64 `json:"longitude"`
+				} `json:"coordinate"`
+				Current bool `json:"is_current_location"`
+			} `json:"target"`
+		} `json:"story_attachment"`
+	}
+	if err := putJSONIntoObject(raw, &obj); err != nil {
+		return nil, err
+	}
+	story := obj.Story
+	if story.Target.TypeName != extensibleLocationType &&
+		story.Target.TypeName != extensibleLiveLocationType {
+		return nil, errors.New("unexpected type: " + story.Target.TypeName)
+	}
+	return &LocationAttachment{
+		Latitude:  story.Target.Coordinate.Latitude,
+		Longitude: story.Target.Coordinate.Longitude,
+		Name:      story.Title.Text,
+		Address:   story.Description.Text,
+		Current:   story.Target.Current,
+		Live:      story.Target.TypeName == extensibleLiveLocationType,
+		MapURL:    story.URL,
+		ImageURL:  story.Media.Image.URI,
+	}, nil
+}
+
+// AttachmentType returns the internal attachment type for
+// location attachments.
+func (l *LocationAttachment) AttachmentType() string {
+	return LocationAttachmentType
+}
+
+// URL returns the URL of a map showing the location.
+func (l *LocationAttachment) URL() string {
+	return l.MapURL
+}
+
+// String returns a brief description of the attachment.
+func (l *LocationAttachment) String() string {
+	return "LocationAttachment<" + strconv.FormatFloat(l.Latitude, 'f', -1, 64) + "," +
+		strconv.FormatFloat(l.Longitude, 'f', -1, 64) + ">"
+}
+
+// A ShareAttachment is an attachment for a shared link,
+// such as a web page or a Facebook post.
+type ShareAttachment struct {
+	// ShareURL is the URL that was shared.
+	ShareURL string
+
+	Title       string
+	Description string
+
+	// Source is the name of the site or page that the
+	// link refers to, such as "youtube.com".
+	Source string
+
+	// ImageURL is the URL of the preview image, if there
+	// is one.
+	ImageURL string
+}
+
+func decodeShareAttachment(raw map[string]interface{}) (*ShareAttachment, error) {
+	var obj struct {
+		Mercury struct {
+			Type  string `json:"attach_type"`
+			Share struct {
+				URI         string `json:"uri"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+				Source      string `json:"source"`
+				Media       struct {
+					Image string `json:"image"`
+				} `json:"media"`
+			} `json:"share"`
+		} `json:"mercury"`
+	}
+	if err := putJSONIntoObject(raw, &obj); err != nil {
+		return nil, err
+	}
+	if obj.Mercury.Type != ShareAttachmentType {
+		return nil, errors.New("unexpected type: " + obj.Mercury.Type)
+	}
+	share := obj.Mercury.Share
+	return &ShareAttachment{
+		ShareURL:    unwrapShareURL(share.URI),
+		Title:       share.Title,
+		Description: share.Description,
+		Source:      share.Source,
+		ImageURL:    share.Media.Image,
+	}, nil
+}
+
+func decodeExtensibleShareAttachment(raw map[string]interface{}) (*ShareAttachment, error) {
+	var obj struct {
+		Story *struct {
+			URL   string `json:"url"`
+			Title struct {
+				Text string `json:"text"`
+			} `json:"title_with_entities"`
+			Description struct {
+				Text string `json:"text"`
+			} `json:"description"`
+			Source struct {
+				Text string `json:"text"`
+			} `json:"source"`
+			Media struct {
+				Image uriField `json:"image"`
+			} `json:"media"`
+		} `json:"story_attachment"`
+	}
+	if err := putJSONIntoObject(raw, &obj); err != nil {
+		return nil, err
+	}
+	if obj.Story == nil || obj.Story.URL == "" {
+		return nil, errors.New("not a share")
+	}
+	story := obj.Story
+	return &ShareAttachment{
+		ShareURL:    unwrapShareURL(story.URL),
+		Title:       story.Title.Text,
+		Description: story.Description.Text,
+		Source:      story.Source.Text,
+		ImageURL:    story.Media.Image.URI,
+	}, nil
+}
+
+// AttachmentType returns the internal attachment type for
+// share attachments.
+func (s *ShareAttachment) AttachmentType() string {
+	return ShareAttachmentType
+}
+
+// URL returns the shared URL.
+func (s *ShareAttachment) URL() string {
+	return s.ShareURL
+}
+
+// String returns a brief description of the attachment.
+func (s *ShareAttachment) String() string {
+	return "ShareAttachment<" + s.URL() + ">"
+}
+
+// unwrapShareURL removes Facebook's redirect wrapper from
+// an external link.
+func unwrapShareURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Path != "/l.php" ||
+		!strings.HasSuffix(parsed.Host, "facebook.com") {
+		return rawURL
+	}
+	if target := parsed.Query().Get("u"); target != "" {
+		return target
+	}
+	return rawURL
+}
@@ -24,6 +24,10 @@ const (
 	SpoofedUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.11; rv:43.0) Gecko/20100101 Firefox/43.0"
 )
 
+// sentIDsCapacity is the number of recently generated
+// offline threading IDs a Session remembers.
+const sentIDsCapacity = 1000
+
 // A Session is an authenticated session with the
 // messenger backend.
 type Session struct {
@@ -40,6 +44,21 @@ type Session struct {
 
 	randLock sync.Mutex
 	randGen  *rand.Rand
+
+	// sentIDs stores offline threading IDs for messages
+	// sent through this session.
+	sentIDs *lruSet
+
+	rateLimitLock sync.Mutex
+	rateLimiter   *rateLimiter
+
+	// closed is closed by Close.
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	echoLock         sync.Mutex
+	echoWaiters      map[*echoWaiter]bool
+	connectedStreams int
 }
 
 // Auth creates a new Session by authenticating with the
@@ -119,6 +138,8 @@ func sessionForHomepage(c *http.Client, body io.Reader) (*Session, error) {
 		Client:  c,
 		userID:  userID,
 		randGen: rand.New(rand.NewSource(time.Now().UnixNano())),
+		sentIDs: newLRUSet(sentIDsCapacity),
+		closed:  make(chan struct{}),
 	}, nil
 }
 
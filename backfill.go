@@ -0,0 +1,132 @@
+package fbmsgr
+
+import (
+	"sort"
+	"time"
+)
+
+// backfillPageSize is the number of actions fetched at a
+// time when backfilling a thread.
+// It is small since most threads only miss a few messages.
+const backfillPageSize = 20
+
+// backfill fetches the messages which were sent after the
+// given time and emits them as backfilled MessageEvents in
+// chronological order.
+func (e *EventStream) backfill(since time.Time) error {
+	threads, err := e.threadsUpdatedSince(since)
+	if err != nil {
+		return err
+	}
+
+	var events []MessageEvent
+	for _, thread := range threads {
+		threadEvents, err := e.threadMessagesSince(thread, since)
+		if err != nil {
+			return err
+		}
+		events = append(events, threadEvents...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	for _, evt := range events {
+		if e.checkClosed() {
+			break
+		}
+		if e.seenDeltas != nil {
+			if e.seenDeltas.Add(deltaIdentity("NewMessage", evt.MessageID, nil)) {
+				continue
+			}
+		}
+		e.emitMessage(evt)
+	}
+	return nil
+}
+
+func (e *EventStream) threadsUpdatedSince(since time.Time) ([]*ThreadInfo, error) {
+	var res []*ThreadInfo
+	seen := map[string]bool{}
+	var before time.Time
+	for {
+		listing, err := e.session.Threads(before, threadBufferSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, thread := range listing {
+			if thread.UpdatedTime.After(since) && !seen[thread.ThreadFBID] {
+				seen[thread.ThreadFBID] = true
+				res = append(res, thread)
+			}
+		}
+		if len(listing) < threadBufferSize ||
+			!listing[len(listing)-1].UpdatedTime.After(since) {
+			return res, nil
+		}
+		before = listing[len(listing)-1].UpdatedTime
+	}
+}
+
+func (e *EventStream) threadMessagesSince(thread *ThreadInfo,
+	since time.Time) ([]MessageEvent, error) {
+	var res []MessageEvent
+	seen := map[string]bool{}
+	var before time.Time
+	for {
+		listing, err := e.session.ActionLog(thread.ThreadFBID, before, backfillPageSize)
+		if err != nil {
+			return nil, err
+		}
+		done := len(listing) < backfillPageSize
+		for i := len(listing) - 1; i >= 0; i-- {
+			action := listing[i]
+			if !action.ActionTime().After(since) {
+				done = true
+				break
+			}
+			// Pages overlap by one action.
+			if msg, ok := action.(*MessageAction); ok && !seen[msg.MessageID()] {
+				seen[msg.MessageID()] = true
+				res = append(res, e.backfilledMessage(thread, msg))
+			}
+		}
+		if done || listing[0].ActionTime().Equal(before) {
+			return res, nil
+		}
+		before = listing[0].ActionTime()
+	}
+}
+
+func (e *EventStream) backfilledMessage(thread *ThreadInfo, msg *MessageAction) MessageEvent {
+	res := MessageEvent{
+		MessageID:   msg.MessageID(),
+		Body:        msg.Body,
+		Attachments: msg.Attachments,
+		Mentions:    msg.Mentions,
+		SenderFBID:  msg.AuthorFBID(),
+		Timestamp:   msg.ActionTime(),
+		Backfilled:  true,
+
+		RepliedToMessageID: msg.RepliedToMessageID,
+		RepliedToSnippet:   msg.RepliedToSnippet,
+
+		EphemeralTTL: msg.EphemeralTTL,
+		Expiration:   msg.Expiration,
+	}
+	if thread.OtherUserID != nil {
+		res.OtherUser = *thread.OtherUserID
+	} else {
+		res.GroupThread = thread.ThreadFBID
+	}
+	res.OfflineThreadingID, _ = msg.RawData["offline_threading_id"].(string)
+	res.FromSession = res.OfflineThreadingID != "" &&
+		e.session.sentIDs.Contains(res.OfflineThreadingID)
+	rawTags, _ := msg.RawData["tags_list"].([]interface{})
+	for _, tag := range rawTags {
+		if tag, ok := tag.(string); ok {
+			res.Tags = append(res.Tags, tag)
+		}
+	}
+	return res
+}
@@ -0,0 +1,154 @@
+package fbmsgr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackfillOnReconnect(t *testing.T) {
+	start := time.Now()
+	millis := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano()/1e6, 10)
+	}
+
+	// The first action predates the disconnect, and the rest
+	// were missed.
+	var actions []map[string]interface{}
+	for i := 0; i <= 25; i++ {
+		ts := start.Add(time.Second * time.Duration(i))
+		if i == 0 {
+			ts = start.Add(-time.Hour)
+		}
+		actions = append(actions, map[string]interface{}{
+			"__typename":        MessageActionType,
+			"message_id":        "mid." + strconv.Itoa(i),
+			"timestamp_precise": millis(ts),
+			"message":           map[string]interface{}{"text": "msg " + strconv.Itoa(i)},
+			"message_sender":    map[string]interface{}{"id": "5"},
+		})
+	}
+
+	var lock sync.Mutex
+	var actionLogRequests int
+	session := testSession(t, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if r.URL.Path != "/api/graphqlbatch" {
+			http.NotFound(w, r)
+			return
+		}
+		var queries struct {
+			Query struct {
+				DocID  string `json:"doc_id"`
+				Params struct {
+					Before *string `json:"before"`
+					Limit  int     `json:"message_limit"`
+				} `json:"query_params"`
+			} `json:"o0"`
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("queries")), &queries); err != nil {
+			t.Error(err)
+			return
+		}
+		var data interface{}
+		switch queries.Query.DocID {
+		case threadLogDocID:
+			data = map[string]interface{}{
+				"viewer": map[string]interface{}{
+					"message_threads": map[string]interface{}{
+						"nodes": []interface{}{
+							map[string]interface{}{
+								"thread_key":           map[string]interface{}{"other_user_id": "5"},
+								"updated_time_precise": millis(start.Add(time.Minute)),
+							},
+						},
+					},
+				},
+			}
+		case actionLogDocID:
+			lock.Lock()
+			actionLogRequests++
+			lock.Unlock()
+			var page []map[string]interface{}
+			for _, action := range actions {
+				if before := queries.Query.Params.Before; before == nil ||
+					action["timestamp_precise"].(string) <= *before {
+					page = append(page, action)
+				}
+			}
+			if limit := queries.Query.Params.Limit; len(page) > limit {
+				page = page[len(page)-limit:]
+			}
+			data = map[string]interface{}{
+				"message_thread": map[string]interface{}{
+					"messages": map[string]interface{}{"nodes": page},
+				},
+			}
+		default:
+			t.Errorf("unexpected doc: %s", queries.Query.DocID)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"o0": map[string]interface{}{"data": data},
+		})
+	}))
+
+	transport := &reconnectTransport{reconnect: make(chan struct{})}
+	stream := session.EventStreamWithOptions(&StreamOptions{
+		Transport: transport,
+		Backfill:  true,
+	})
+	defer stream.Close()
+	select {
+	case <-stream.connected:
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for connection")
+	}
+	close(transport.reconnect)
+
+	for i := 1; i <= 25; i++ {
+		select {
+		case evt := <-stream.Chan():
+			msg, ok := evt.(MessageEvent)
+			if !ok {
+				t.Fatalf("unexpected event: %#v", evt)
+			}
+			if !msg.Backfilled || msg.MessageID != "mid."+strconv.Itoa(i) ||
+				msg.Body != "msg "+strconv.Itoa(i) || msg.OtherUser != "5" {
+				t.Errorf("unexpected message %d: %+v", i, msg)
+			}
+		case <-time.After(testTimeout):
+			t.Fatal("timed out waiting for backfill")
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if actionLogRequests != 2 {
+		t.Errorf("expected 2 action log requests but got %d", actionLogRequests)
+	}
+}
+
+// reconnectTransport is a Transport which connects, and
+// then reconnects after missing some messages when
+// reconnect is closed.
+// It never delivers messages itself.
+type reconnectTransport struct {
+	reconnect chan struct{}
+}
+
+func (r *reconnectTransport) Run(ctx context.Context, _ *Session, sink TransportSink) error {
+	sink.Connected()
+	select {
+	case <-r.reconnect:
+	case <-ctx.Done():
+		return nil
+	}
+	sink.Interrupted()
+	sink.Connected()
+	<-ctx.Done()
+	return nil
+}
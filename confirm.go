@@ -0,0 +1,147 @@
+package fbmsgr
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// ErrNotConfirmed is returned by SendAndConfirm when the
+// sent message is not observed before the timeout.
+var ErrNotConfirmed = errors.New("message not confirmed")
+
+// echoWaiter waits for a MessageEvent matching a message
+// that was sent by the session.
+type echoWaiter struct {
+	offlineThreadingID string
+	messageID          string
+	confirmed          chan struct{}
+}
+
+// SendAndConfirm sends a message and waits until the
+// message shows up as a MessageEvent in one of the
+// session's EventStreams.
+//
+// The echoed event is matched by its offline threading ID
+// or its message ID.
+// If no EventStream is connected for the session, a
+// temporary one is started, and the message is only sent
+// once the stream is ready to receive the echo.
+// If the temporary stream fails or does not connect
+// within the timeout, the message is not sent.
+//
+// If the message is sent but not confirmed within the
+// timeout, the message ID is returned along with
+// ErrNotConfirmed (possibly with added context).
+// The timeout starts once the message has been sent.
+func (s *Session) SendAndConfirm(thread ThreadID, msg *Message,
+	timeout time.Duration) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send and confirm", &err)
+
+	msgCopy := *msg
+	if msgCopy.OfflineThreadingID == "" {
+		msgCopy.OfflineThreadingID = s.randomMessageID()
+	}
+	waiter := &echoWaiter{
+		offlineThreadingID: msgCopy.OfflineThreadingID,
+		confirmed:          make(chan struct{}),
+	}
+
+	s.echoLock.Lock()
+	if s.echoWaiters == nil {
+		s.echoWaiters = map[*echoWaiter]bool{}
+	}
+	s.echoWaiters[waiter] = true
+	needStream := s.connectedStreams == 0
+	s.echoLock.Unlock()
+
+	defer func() {
+		s.echoLock.Lock()
+		delete(s.echoWaiters, waiter)
+		s.echoLock.Unlock()
+	}()
+
+	if needStream {
+		stream := s.EventStream()
+		defer stream.Close()
+		if err := waitStreamConnected(stream, timeout); err != nil {
+			return "", err
+		}
+	}
+
+	msgID, err = s.send(thread, &msgCopy)
+	if err != nil {
+		return "", err
+	}
+
+	s.echoLock.Lock()
+	waiter.messageID = msgID
+	s.echoLock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-waiter.confirmed:
+		return msgID, nil
+	case <-timer.C:
+		return msgID, ErrNotConfirmed
+	}
+}
+
+// waitStreamConnected drains a stream's events in the
+// background and waits for it to connect.
+func waitStreamConnected(stream *EventStream, timeout time.Duration) error {
+	ended := make(chan struct{})
+	go func() {
+		for range stream.Chan() {
+		}
+		close(ended)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stream.connected:
+		return nil
+	case <-ended:
+		if err := stream.Error(); err != nil {
+			return err
+		}
+		return errors.New("event stream closed")
+	case <-timer.C:
+		return errors.New("event stream did not connect")
+	}
+}
+
+// streamConnected records that an EventStream is ready to
+// receive events.
+func (s *Session) streamConnected() {
+	s.echoLock.Lock()
+	s.connectedStreams++
+	s.echoLock.Unlock()
+}
+
+// streamDisconnected records that a connected EventStream
+// has stopped.
+func (s *Session) streamDisconnected() {
+	s.echoLock.Lock()
+	s.connectedStreams--
+	s.echoLock.Unlock()
+}
+
+// observeMessage confirms any sends which are waiting for
+// the message.
+func (s *Session) observeMessage(evt *MessageEvent) {
+	s.echoLock.Lock()
+	defer s.echoLock.Unlock()
+	for waiter := range s.echoWaiters {
+		matchesOTID := evt.OfflineThreadingID != "" &&
+			evt.OfflineThreadingID == waiter.offlineThreadingID
+		matchesID := evt.MessageID != "" && evt.MessageID == waiter.messageID
+		if matchesOTID || matchesID {
+			close(waiter.confirmed)
+			delete(s.echoWaiters, waiter)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package fbmsgr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSendAndConfirm(t *testing.T) {
+	deltas := make(chan map[string]interface{}, 1)
+	session := testSession(t, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if r.URL.Path != "/messaging/send/" {
+			http.NotFound(w, r)
+			return
+		}
+		otid := r.FormValue("offline_threading_id")
+		deltas <- map[string]interface{}{
+			"type": "delta",
+			"delta": map[string]interface{}{
+				"class": "NewMessage",
+				"body":  r.FormValue("body"),
+				"messageMetadata": map[string]interface{}{
+					"messageId":          "mid.1",
+					"offlineThreadingId": otid,
+					"actorFbId":          "1234",
+					"timestamp":          "1000",
+					"threadKey": map[string]interface{}{
+						"otherUserFbId": r.FormValue("other_user_fbid"),
+					},
+				},
+			},
+		}
+		w.Write([]byte(`for (;;);{"payload":{"actions":[{"message_id":"mid.1"}]}}`))
+	}))
+
+	stream := session.EventStreamWithOptions(&StreamOptions{
+		Transport: &stubTransport{ConnectDelay: time.Millisecond * 50, Messages: deltas},
+	})
+	defer stream.Close()
+	go func() {
+		for range stream.Chan() {
+		}
+	}()
+	if err := waitStreamConnected(stream, testTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	msgID, err := session.SendAndConfirm(UserThreadID("5"), &Message{Body: "hi"}, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgID != "mid.1" {
+		t.Errorf("unexpected message ID: %s", msgID)
+	}
+}
+
+func TestWaitStreamConnected(t *testing.T) {
+	session := testSession(t, http.NotFoundHandler())
+
+	stream := session.EventStreamWithOptions(&StreamOptions{
+		Transport: &stubTransport{ConnectDelay: time.Millisecond * 50},
+	})
+	start := time.Now()
+	if err := waitStreamConnected(stream, testTimeout); err != nil {
+		t.Error(err)
+	} else if time.Since(start) < time.Millisecond*50 {
+		t.Error("returned before the transport connected")
+	}
+	if n := connectedStreams(session); n != 1 {
+		t.Errorf("unexpected connected streams: %d", n)
+	}
+	stream.Close()
+
+	failing := session.EventStreamWithOptions(&StreamOptions{
+		Transport: &stubTransport{Err: errors.New("setup failed")},
+	})
+	if err := waitStreamConnected(failing, testTimeout); err == nil ||
+		err.Error() != "setup failed" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	stuck := session.EventStreamWithOptions(&StreamOptions{
+		Transport: &stubTransport{ConnectDelay: time.Hour},
+	})
+	defer stuck.Close()
+	if err := waitStreamConnected(stuck, time.Millisecond*50); err == nil {
+		t.Error("expected timeout")
+	}
+
+	// The closed stream stops in the background.
+	deadline := time.Now().Add(testTimeout)
+	for connectedStreams(session) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream was never disconnected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func connectedStreams(s *Session) int {
+	s.echoLock.Lock()
+	defer s.echoLock.Unlock()
+	return s.connectedStreams
+}
+
+// stubTransport is a Transport which connects after a
+// delay and then forwards messages from a channel.
+type stubTransport struct {
+	ConnectDelay time.Duration
+	Err          error
+	Messages     <-chan map[string]interface{}
+}
+
+func (s *stubTransport) Run(ctx context.Context, _ *Session, sink TransportSink) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	select {
+	case <-time.After(s.ConnectDelay):
+	case <-ctx.Done():
+		return nil
+	}
+	sink.Connected()
+	for {
+		select {
+		case msg := <-s.Messages:
+			sink.Messages([]map[string]interface{}{msg})
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
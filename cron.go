@@ -0,0 +1,182 @@
+package fbmsgr
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit bounds how far in the future the next
+// time of a cron schedule is searched for.
+const cronSearchLimit = 5
+
+// cronMaxClockShift is the largest amount by which clocks
+// are assumed to be turned back at once, such as at the
+// end of daylight saving time.
+const cronMaxClockShift = time.Hour * 3
+
+// A cronSchedule is a parsed cron expression.
+type cronSchedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+
+	// anyDay and anyWeekday record whether the day fields
+	// were "*", which affects how they are combined.
+	anyDay     bool
+	anyWeekday bool
+}
+
+// parseCron parses a standard five-field cron expression,
+// "minute hour day-of-month month day-of-week".
+//
+// Each field may be "*", a number, a range like "1-5", a
+// step like "*/15" or "0-30/10", or a comma-separated list
+// of these.
+// For the day of the week, 0 and 7 are both Sunday.
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must have five fields")
+	}
+	ranges := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]map[int]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, ranges[i][0], ranges[i][1])
+		if err != nil {
+			return nil, errors.New("cron field " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &cronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	res := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step < 1 {
+				return nil, errors.New("invalid step: " + part)
+			}
+			part = part[:idx]
+		}
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.New("invalid value: " + part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.New("invalid value: " + part)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, errors.New("value out of range: " + part)
+		}
+		for i := start; i <= end; i += step {
+			res[i] = true
+		}
+	}
+	return res, nil
+}
+
+// Next finds the first time strictly after t which
+// matches the schedule.
+// It returns the zero time if there is no such time in
+// the foreseeable future.
+//
+// Times are matched by their wall clock in t's location.
+// A wall clock time which does not exist, because clocks
+// were turned forward, is never matched.
+// A wall clock time which occurs twice, because clocks
+// were turned back, is only matched the first time.
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchLimit, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		loc := t.Location()
+		if !c.months[int(m)] {
+			t = cronAdvance(t, time.Date(y, m+1, 1, 0, 0, 0, 0, loc))
+		} else if !c.dayMatches(t) {
+			t = cronAdvance(t, time.Date(y, m, d+1, 0, 0, 0, 0, loc))
+		} else if !c.hours[t.Hour()] {
+			t = cronNextHour(t)
+		} else if !c.minutes[t.Minute()] || repeatedWallClock(t) {
+			t = t.Add(time.Minute)
+		} else {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dayMatch := c.days[t.Day()]
+	weekdayMatch := c.weekdays[int(t.Weekday())]
+	if c.anyDay || c.anyWeekday {
+		return dayMatch && weekdayMatch
+	}
+	return dayMatch || weekdayMatch
+}
+
+// cronAdvance returns next if it is after t.
+//
+// Otherwise, next is a wall clock time that does not
+// exist, such as a midnight skipped by turning clocks
+// forward, and time.Date chose an earlier time for it.
+// In this case, the start of the next hour is returned.
+func cronAdvance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return cronNextHour(t)
+}
+
+// cronNextHour returns the start of the next hour after a
+// time with no seconds.
+//
+// Unlike time.Date, this never goes backwards when clocks
+// are turned forward.
+func cronNextHour(t time.Time) time.Time {
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+// repeatedWallClock checks if t's wall clock time already
+// occurred shortly before t, because clocks were turned
+// back.
+func repeatedWallClock(t time.Time) bool {
+	_, offset := t.Zone()
+	_, prevOffset := t.Add(-cronMaxClockShift).Zone()
+	if prevOffset <= offset {
+		return false
+	}
+	earlier := t.Add(-time.Duration(prevOffset-offset) * time.Second)
+	_, earlierOffset := earlier.Zone()
+	return earlierOffset == prevOffset
+}
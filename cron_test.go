@@ -0,0 +1,184 @@
+package fbmsgr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	testCases := []struct {
+		Field    string
+		Min      int
+		Max      int
+		Expected []int
+	}{
+		{"5", 0, 59, []int{5}},
+		{"1-4", 1, 12, []int{1, 2, 3, 4}},
+		{"*/15", 0, 59, []int{0, 15, 30, 45}},
+		{"0-30/10", 0, 59, []int{0, 10, 20, 30}},
+		{"5/20", 0, 59, []int{5, 25, 45}},
+		{"1,3-4,10-12/2", 1, 12, []int{1, 3, 4, 10, 12}},
+		{"*/5", 1, 12, []int{1, 6, 11}},
+	}
+	for _, tc := range testCases {
+		set, err := parseCronField(tc.Field, tc.Min, tc.Max)
+		if err != nil {
+			t.Errorf("%s: %s", tc.Field, err)
+			continue
+		}
+		var actual []int
+		for i := tc.Min; i <= tc.Max; i++ {
+			if set[i] {
+				actual = append(actual, i)
+			}
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Errorf("%s: expected %v but got %v", tc.Field, tc.Expected, actual)
+		}
+	}
+
+	for _, spec := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1- * * * *",
+	} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, time.UTC)
+	}
+	testCases := []struct {
+		Spec     string
+		Start    time.Time
+		Expected time.Time
+	}{
+		// Results are strictly after the start time.
+		{"*/15 * * * *", utc(2024, 1, 1, 10, 7), utc(2024, 1, 1, 10, 15)},
+		{"*/15 * * * *", utc(2024, 1, 1, 10, 15), utc(2024, 1, 1, 10, 30)},
+		{"*/15 * * * *", utc(2024, 1, 1, 23, 50), utc(2024, 1, 2, 0, 0)},
+
+		// Friday 10am to Monday 9am.
+		{"0 9 * * 1-5", utc(2024, 3, 8, 10, 0), utc(2024, 3, 11, 9, 0)},
+
+		// Both 0 and 7 mean Sunday.
+		{"0 9 * * 0", utc(2024, 3, 8, 10, 0), utc(2024, 3, 10, 9, 0)},
+		{"0 9 * * 7", utc(2024, 3, 8, 10, 0), utc(2024, 3, 10, 9, 0)},
+
+		// If both day fields are restricted, either may match.
+		// January 5th 2024 is a Friday, and the 13th is a
+		// Saturday.
+		{"0 0 13 * 5", utc(2024, 1, 1, 0, 0), utc(2024, 1, 5, 0, 0)},
+		{"0 0 13 * 5", utc(2024, 1, 12, 0, 0), utc(2024, 1, 13, 0, 0)},
+
+		// If either day field is "*", the other must match.
+		{"0 0 13 * *", utc(2024, 1, 1, 0, 0), utc(2024, 1, 13, 0, 0)},
+		{"0 0 * * 5", utc(2024, 1, 6, 0, 0), utc(2024, 1, 12, 0, 0)},
+		{"0 0 1-7 * */7", utc(2024, 1, 8, 0, 0), utc(2024, 1, 14, 0, 0)},
+
+		// Months and years roll over.
+		{"30 2 1 1 *", utc(2024, 12, 31, 12, 0), utc(2025, 1, 1, 2, 30)},
+		{"0 0 29 2 *", utc(2025, 1, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"0 12 31 * *", utc(2024, 4, 1, 0, 0), utc(2024, 5, 31, 12, 0)},
+
+		// Impossible dates never match.
+		{"* * 31 2 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+		{"0 0 30 2 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tc := range testCases {
+		schedule, err := parseCron(tc.Spec)
+		if err != nil {
+			t.Errorf("%s: %s", tc.Spec, err)
+			continue
+		}
+		actual := schedule.Next(tc.Start)
+		if !actual.Equal(tc.Expected) {
+			t.Errorf("%s after %s: expected %s but got %s", tc.Spec, tc.Start,
+				tc.Expected, actual)
+		}
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data unavailable:", err)
+	}
+	// On 2024-03-10, 2am EST became 3am EDT.
+	// On 2024-11-03, 2am EDT became 1am EST.
+	utc := func(m time.Month, d, h, min int) time.Time {
+		return time.Date(2024, m, d, h, min, 0, 0, time.UTC).In(loc)
+	}
+	testCases := []struct {
+		Spec     string
+		Start    time.Time
+		Expected time.Time
+	}{
+		// 2:30am does not exist on the first day of DST.
+		{"30 2 * * *", utc(3, 10, 5, 0), utc(3, 11, 6, 30)},
+		{"0 * * * *", utc(3, 10, 6, 30), utc(3, 10, 7, 0)},
+		{"30 3 * * *", utc(3, 10, 5, 0), utc(3, 10, 7, 30)},
+
+		// 1:30am happens twice at the end of DST, but only
+		// the first one matches.
+		{"30 1 * * *", utc(11, 3, 4, 0), utc(11, 3, 5, 30)},
+		{"30 1 * * *", utc(11, 3, 5, 30), utc(11, 4, 6, 30)},
+		{"0 * * * *", utc(11, 3, 5, 0), utc(11, 3, 7, 0)},
+		{"*/20 * * * *", utc(11, 3, 5, 50), utc(11, 3, 7, 0)},
+		{"15 3 * * *", utc(11, 3, 4, 0), utc(11, 3, 8, 15)},
+
+		// The search continues correctly from a time in the
+		// repeated hour.
+		{"30 1 * * *", utc(11, 3, 6, 10), utc(11, 4, 6, 30)},
+		{"0 2 * * *", utc(11, 3, 6, 10), utc(11, 3, 7, 0)},
+	}
+	for _, tc := range testCases {
+		schedule, err := parseCron(tc.Spec)
+		if err != nil {
+			t.Errorf("%s: %s", tc.Spec, err)
+			continue
+		}
+		actual := schedule.Next(tc.Start)
+		if !actual.Equal(tc.Expected) {
+			t.Errorf("%s after %s: expected %s but got %s", tc.Spec, tc.Start,
+				tc.Expected, actual)
+		}
+	}
+}
+
+func TestCronNextSkippedMidnight(t *testing.T) {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip("time zone data unavailable:", err)
+	}
+	// On 2018-11-04, midnight became 1am.
+	start := time.Date(2018, 11, 3, 10, 0, 0, 0, loc)
+	testCases := map[string]time.Time{
+		"0 0 * * 0":  time.Date(2018, 11, 11, 0, 0, 0, 0, loc),
+		"30 1 * * 0": time.Date(2018, 11, 4, 1, 30, 0, 0, loc),
+		"0 12 4 * *": time.Date(2018, 11, 4, 12, 0, 0, 0, loc),
+	}
+	for spec, expected := range testCases {
+		schedule, err := parseCron(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := schedule.Next(start)
+		if !actual.Equal(expected) {
+			t.Errorf("%s: expected %s but got %s", spec, expected, actual)
+		}
+	}
+}
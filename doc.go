@@ -28,6 +28,15 @@
 //     sess.SendText("USER_FBID", "what's up?")
 //     sess.SendGroupText("GROUP_FBID", "what's up?")
 //
+// The Send method works for users and groups alike, and
+// can combine text with attachments, stickers, mentions,
+// and replies:
+//
+//     sess.Send(fbmsgr.GroupThreadID("GROUP_FBID"), &fbmsgr.Message{
+//         Body:    "look at this",
+//         ReplyTo: "MESSAGE_ID",
+//     })
+//
 // To send or retract a typing notification, you might do:
 //
 //     sess.SendTyping("USER_FBID", true) // typing
@@ -102,6 +111,27 @@
 // You can also create multiple EventStreams and read from
 // different streams in different places.
 //
+// By default, event streams use Messenger's long-polling
+// endpoint.
+// To receive events with MQTT over a WebSocket (as the
+// current web client does), use an MQTTTransport:
+//
+//     stream := sess.EventStreamWithOptions(&fbmsgr.StreamOptions{
+//         Transport: &fbmsgr.MQTTTransport{},
+//     })
+//
+// Events can be saved with an EventRecorder and replayed
+// later with ReplayEvents, which produces an EventStream
+// that does not require a Session:
+//
+//     recorder := fbmsgr.NewEventRecorder(logFile)
+//     for evt := range stream.Chan() {
+//         recorder.Record(evt)
+//     }
+//
+//     // Later on, replay the events twice as fast.
+//     replay := fbmsgr.ReplayEvents(logFile, 2)
+//
 // Listing threads
 //
 // To list the threads (conversations) a user is in, you
@@ -0,0 +1,80 @@
+package fbmsgr
+
+import (
+	"strconv"
+	"time"
+)
+
+// An EphemeralTTL is the lifetime of a disappearing
+// message, measured in seconds.
+//
+// Messenger only accepts the values listed below.
+// NoEphemeralTTL indicates a normal message.
+type EphemeralTTL int
+
+const (
+	NoEphemeralTTL         EphemeralTTL = 0
+	EphemeralFiveSeconds   EphemeralTTL = 5
+	EphemeralTenSeconds    EphemeralTTL = 10
+	EphemeralThirtySeconds EphemeralTTL = 30
+	EphemeralOneMinute     EphemeralTTL = 60
+	EphemeralFiveMinutes   EphemeralTTL = 60 * 5
+	EphemeralTenMinutes    EphemeralTTL = 60 * 10
+	EphemeralThirtyMinutes EphemeralTTL = 60 * 30
+	EphemeralOneHour       EphemeralTTL = 60 * 60
+	EphemeralSixHours      EphemeralTTL = 60 * 60 * 6
+	EphemeralTwelveHours   EphemeralTTL = 60 * 60 * 12
+	EphemeralOneDay        EphemeralTTL = 60 * 60 * 24
+)
+
+var supportedEphemeralTTLs = map[EphemeralTTL]bool{
+	NoEphemeralTTL:         true,
+	EphemeralFiveSeconds:   true,
+	EphemeralTenSeconds:    true,
+	EphemeralThirtySeconds: true,
+	EphemeralOneMinute:     true,
+	EphemeralFiveMinutes:   true,
+	EphemeralTenMinutes:    true,
+	EphemeralThirtyMinutes: true,
+	EphemeralOneHour:       true,
+	EphemeralSixHours:      true,
+	EphemeralTwelveHours:   true,
+	EphemeralOneDay:        true,
+}
+
+// Supported checks if Messenger accepts the TTL.
+func (e EphemeralTTL) Supported() bool {
+	return supportedEphemeralTTLs[e]
+}
+
+// Duration converts the TTL to a time.Duration.
+func (e EphemeralTTL) Duration() time.Duration {
+	return time.Duration(e) * time.Second
+}
+
+// ephemeralExpiration computes when a message sent at the
+// given time will disappear.
+// It returns the zero time for a normal message.
+func ephemeralExpiration(ttl EphemeralTTL, sent time.Time) time.Time {
+	if ttl == NoEphemeralTTL || sent.IsZero() {
+		return time.Time{}
+	}
+	return sent.Add(ttl.Duration())
+}
+
+// parseEphemeralTTL decodes a TTL, encoded either as a
+// string or as a float64.
+// It returns NoEphemeralTTL if the TTL is invalid.
+func parseEphemeralTTL(val interface{}) EphemeralTTL {
+	switch val := val.(type) {
+	case string:
+		parsed, err := strconv.Atoi(val)
+		if err != nil {
+			return NoEphemeralTTL
+		}
+		return EphemeralTTL(parsed)
+	case float64:
+		return EphemeralTTL(val)
+	}
+	return NoEphemeralTTL
+}
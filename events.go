@@ -1,20 +1,20 @@
 package fbmsgr
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"io"
-	"net/url"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/unixpickle/essentials"
 )
 
-const pollErrTimeout = time.Second * 5
+// DefaultDedupWindow is the default number of recent
+// deltas an EventStream remembers in order to suppress
+// duplicates.
+const DefaultDedupWindow = 1000
 
 // An Event is a notification pushed to the client by the
 // server.
@@ -44,6 +44,63 @@ type MessageEvent struct {
 	// If non-empty, this specifies the other user in a
 	// one-on-one chat (as opposed to a group chat).
 	OtherUser string
+
+	// Timestamp is the time when the server received the
+	// message.
+	Timestamp time.Time
+
+	// OfflineThreadingID is the client-generated ID that
+	// the sender attached to the message.
+	OfflineThreadingID string
+
+	// Tags contains Messenger's tags for the message, such
+	// as "source:chat:web" or "inbox".
+	Tags []string
+
+	// Mentions contains the users mentioned in the body.
+	Mentions []Mention
+
+	// RepliedToMessageID is the ID of the message to which
+	// this message replies.
+	// It is "" if the message is not a reply.
+	RepliedToMessageID string
+
+	// RepliedToSnippet is the body of the message to which
+	// this message replies, if it is available.
+	RepliedToSnippet string
+
+	// EphemeralTTL is the lifetime of a disappearing
+	// message.
+	// It is NoEphemeralTTL for a normal message.
+	EphemeralTTL EphemeralTTL
+
+	// Expiration is the time when a disappearing message
+	// will disappear.
+	// It is the zero time for a normal message.
+	Expiration time.Time
+
+	// FromSession is true if the message was sent using
+	// the Session that produced this event, as opposed to
+	// another Session or device.
+	FromSession bool
+
+	// RawDelta contains the delta's raw JSON data.
+	// It is nil for backfilled messages.
+	RawDelta map[string]interface{}
+
+	// Backfilled is true if the message was missed while
+	// the stream was disconnected, and was later fetched
+	// from the thread's history.
+	Backfilled bool
+}
+
+// Thread returns the ThreadID of the chat containing the
+// message.
+func (m MessageEvent) Thread() ThreadID {
+	if m.GroupThread != "" {
+		return GroupThreadID(m.GroupThread)
+	}
+	return UserThreadID(m.OtherUser)
 }
 
 // A BuddyEvent is an Event containing information about a
@@ -79,12 +136,88 @@ type DeleteMessageEvent struct {
 	OtherUser string
 }
 
+// A BackfillFailedEvent is an Event indicating that the
+// stream could not fetch the messages it missed while it
+// was disconnected.
+//
+// BackfillFailedEvents are only emitted by streams created
+// with the Backfill option.
+type BackfillFailedEvent struct {
+	// Since is the time after which messages may have
+	// been missed.
+	Since time.Time
+
+	// Error describes the last failed attempt.
+	Error string
+}
+
+// An UnknownEvent is an Event containing a message that
+// the library does not know how to decode.
+//
+// UnknownEvents are only emitted by streams created with
+// the UnknownEvents option.
+type UnknownEvent struct {
+	// Type is the message's "type" field, such as "delta".
+	Type string
+
+	// Class is the delta's "class" field.
+	// It is "" for non-delta messages.
+	Class string
+
+	// Raw contains the message's raw JSON data.
+	Raw map[string]interface{}
+}
+
+// StreamOptions stores optional settings for an
+// EventStream.
+type StreamOptions struct {
+	// UnknownEvents indicates that the stream should emit
+	// UnknownEvents for messages and deltas which it does
+	// not recognize, rather than dropping them.
+	UnknownEvents bool
+
+	// DedupWindow is the number of recent deltas that the
+	// stream remembers in order to drop duplicates, which
+	// the server may send after a failed poll or after a
+	// reconnect.
+	//
+	// If 0, DefaultDedupWindow is used.
+	// If negative, duplicates are not suppressed.
+	DedupWindow int
+
+	// Backfill indicates that, after recovering from a
+	// connection failure, the stream should fetch messages
+	// which it missed and emit them as MessageEvents with
+	// the Backfilled flag set.
+	// If the messages cannot be fetched, a
+	// BackfillFailedEvent is emitted instead.
+	Backfill bool
+
+	// Transport is the mechanism used to receive events.
+	// If nil, a LongPollTransport is used.
+	Transport Transport
+}
+
 // An EventStream is a live stream of events.
 //
 // Create an event stream using Session.EventStream().
 // Destroy an event stream using EventStream.Close().
 type EventStream struct {
 	session *Session
+	options StreamOptions
+
+	// seenDeltas is nil if de-duplication is disabled.
+	seenDeltas *lruSet
+
+	// lastSeen is the timestamp of the newest message the
+	// stream has emitted.
+	// It is only accessed by the polling Goroutine.
+	lastSeen time.Time
+
+	// connected is closed once the stream's transport is
+	// ready to receive events.
+	connected     chan struct{}
+	connectedOnce sync.Once
 
 	evtChan chan Event
 	ctx     context.Context
@@ -95,11 +228,21 @@ type EventStream struct {
 	closed bool
 }
 
-func newEventStream(s *Session, closed bool) *EventStream {
+func newEventStream(s *Session, opts *StreamOptions, closed bool) *EventStream {
+	if opts == nil {
+		opts = &StreamOptions{}
+	}
 	res := &EventStream{
-		session: s,
-		evtChan: make(chan Event, 1),
-		closed:  closed,
+		session:   s,
+		options:   *opts,
+		evtChan:   make(chan Event, 1),
+		closed:    closed,
+		connected: make(chan struct{}),
+	}
+	if opts.DedupWindow == 0 {
+		res.seenDeltas = newLRUSet(DefaultDedupWindow)
+	} else if opts.DedupWindow > 0 {
+		res.seenDeltas = newLRUSet(opts.DedupWindow)
 	}
 	res.ctx, res.cancel = context.WithCancel(context.Background())
 	if closed {
@@ -145,65 +288,28 @@ func (e *EventStream) Close() error {
 func (e *EventStream) poll() {
 	defer close(e.evtChan)
 
-	host, err := e.callReconnect()
-	if err != nil {
-		e.pollFailed(errors.New("reconnect: " + err.Error()))
-		return
+	defer func() {
+		select {
+		case <-e.connected:
+			e.session.streamDisconnected()
+		default:
+		}
+	}()
+
+	transport := e.options.Transport
+	if transport == nil {
+		transport = &LongPollTransport{}
 	}
-	pool, token, err := e.fetchPollingInfo(host)
-	if err != nil {
+	e.lastSeen = time.Now()
+	err := transport.Run(e.ctx, e.session, &streamSink{stream: e})
+	if err != nil && !e.checkClosed() {
 		e.pollFailed(err)
-		return
-	}
-
-	var seq int
-	startTime := time.Now().Unix()
-	for !e.checkClosed() {
-		values := url.Values{}
-		values.Set("cap", "8")
-		values.Set("cb", "anuk")
-		values.Set("channel", "p_"+e.session.userID)
-		values.Set("clientid", "3342de8f")
-		values.Set("idle", strconv.FormatInt(time.Now().Unix()-startTime, 10))
-		values.Set("isq", "243")
-		values.Set("msgr_region", "FRC")
-		values.Set("msgs_recv", strconv.Itoa(seq))
-		values.Set("partition", "-2")
-		values.Set("pws", "fresh")
-		values.Set("qp", "y")
-		values.Set("seq", strconv.Itoa(seq))
-		values.Set("state", "offline")
-		values.Set("uid", e.session.userID)
-		values.Set("viewer_uid", e.session.userID)
-		values.Set("sticky_pool", pool)
-		values.Set("sticky_token", token)
-		u := "https://0-edge-chat.messenger.com/pull?" + values.Encode()
-		response, err := e.session.jsonForGetContext(e.ctx, u)
-		if e.checkClosed() {
-			return
-		}
-		if err != nil {
-			time.Sleep(pollErrTimeout)
-			continue
-		}
-		msgs, newSeq, err := parseMessages(response)
-		if newSeq > 0 {
-			seq = newSeq
-		}
-		if err != nil {
-			time.Sleep(pollErrTimeout)
-		} else {
-			e.dispatchMessages(msgs)
-		}
 	}
 }
 
 func (e *EventStream) dispatchMessages(msgs []map[string]interface{}) {
 	for _, m := range msgs {
-		t, ok := m["type"].(string)
-		if !ok {
-			continue
-		}
+		t, _ := m["type"].(string)
 		switch t {
 		case "delta":
 			e.dispatchDelta(m)
@@ -211,6 +317,8 @@ func (e *EventStream) dispatchMessages(msgs []map[string]interface{}) {
 			e.dispatchBuddylistOverlay(m)
 		case "ttyp", "typ":
 			e.dispatchTyping(m)
+		default:
+			e.dispatchUnknown(t, "", m)
 		}
 	}
 }
@@ -221,56 +329,169 @@ func (e *EventStream) dispatchDelta(obj map[string]interface{}) {
 			Class string `json:"class"`
 
 			// For message events.
-			Body        string                   `json:"body"`
-			Attachments []map[string]interface{} `json:"attachments"`
-			Meta        struct {
-				Actor     string `json:"actorFbId"`
+			Meta struct {
 				MessageID string `json:"messageId"`
-				ThreadKey struct {
-					ThreadFBID string `json:"threadFbId"`
-					OtherUser  string `json:"otherUserFbId"`
-				} `json:"threadKey"`
 			} `json:"messageMetadata"`
 
 			// For delete events.
 			MessageIDs []string `json:"messageIds"`
 			ThreadKey  struct {
-				ThreadFBID string `json:"threadFbId"`
-				OtherUser  string `json:"otherUserFbId"`
+				ThreadFBID interface{} `json:"threadFbId"`
+				OtherUser  interface{} `json:"otherUserFbId"`
 			} `json:"threadKey"`
 		} `json:"delta"`
 	}
 
 	if putJSONIntoObject(obj, &deltaObj) != nil {
+		e.dispatchUnknown("delta", "", obj)
 		return
 	}
 
-	if deltaObj.Delta.Class == "MessageDelete" {
+	if e.seenDeltas != nil {
+		id := deltaIdentity(deltaObj.Delta.Class, deltaObj.Delta.Meta.MessageID,
+			deltaObj.Delta.MessageIDs)
+		if id != "" && e.seenDeltas.Add(id) {
+			return
+		}
+	}
+
+	rawDelta, _ := obj["delta"].(map[string]interface{})
+	switch deltaObj.Delta.Class {
+	case "MessageDelete":
 		e.emitEvent(DeleteMessageEvent{
 			MessageIDs:  deltaObj.Delta.MessageIDs,
-			GroupThread: deltaObj.Delta.ThreadKey.ThreadFBID,
-			OtherUser:   deltaObj.Delta.ThreadKey.OtherUser,
+			GroupThread: canonicalFBID(deltaObj.Delta.ThreadKey.ThreadFBID),
+			OtherUser:   canonicalFBID(deltaObj.Delta.ThreadKey.OtherUser),
 		})
-		return
+	case "NewMessage":
+		e.dispatchNewMessage(rawDelta, nil)
+	case "ClientPayload":
+		e.dispatchClientPayload(rawDelta)
+	default:
+		e.dispatchUnknown("delta", deltaObj.Delta.Class, obj)
 	}
+}
 
-	if (len(deltaObj.Delta.Attachments) == 0 && deltaObj.Delta.Body == "") ||
-		deltaObj.Delta.Class != "NewMessage" {
+// dispatchNewMessage emits a MessageEvent for a message
+// delta.
+//
+// If the message is a reply, repliedTo may specify the
+// message delta for the original message.
+func (e *EventStream) dispatchNewMessage(rawDelta, repliedTo map[string]interface{}) {
+	var delta struct {
+		Body        string                   `json:"body"`
+		Attachments []map[string]interface{} `json:"attachments"`
+		TTL         interface{}              `json:"ttl"`
+		Data        struct {
+			MentionRanges string `json:"prng"`
+		} `json:"data"`
+		Meta struct {
+			Actor              interface{} `json:"actorFbId"`
+			MessageID          string      `json:"messageId"`
+			OfflineThreadingID string      `json:"offlineThreadingId"`
+			Timestamp          interface{} `json:"timestamp"`
+			Tags               []string    `json:"tags"`
+			ThreadKey          struct {
+				ThreadFBID interface{} `json:"threadFbId"`
+				OtherUser  interface{} `json:"otherUserFbId"`
+			} `json:"threadKey"`
+		} `json:"messageMetadata"`
+		Reply struct {
+			MessageID struct {
+				ID string `json:"id"`
+			} `json:"replyToMessageId"`
+		} `json:"messageReply"`
+	}
+	if putJSONIntoObject(rawDelta, &delta) != nil {
+		return
+	}
+	if len(delta.Attachments) == 0 && delta.Body == "" {
 		return
 	}
 
 	var attachments []Attachment
-	for _, a := range deltaObj.Delta.Attachments {
+	for _, a := range delta.Attachments {
 		attachments = append(attachments, decodeAttachment(a))
 	}
-	e.emitEvent(MessageEvent{
-		MessageID:   deltaObj.Delta.Meta.MessageID,
-		Body:        deltaObj.Delta.Body,
-		Attachments: attachments,
-		SenderFBID:  deltaObj.Delta.Meta.Actor,
-		GroupThread: deltaObj.Delta.Meta.ThreadKey.ThreadFBID,
-		OtherUser:   deltaObj.Delta.Meta.ThreadKey.OtherUser,
-	})
+	meta := delta.Meta
+	evt := MessageEvent{
+		MessageID:          meta.MessageID,
+		Body:               delta.Body,
+		Attachments:        attachments,
+		SenderFBID:         canonicalFBID(meta.Actor),
+		GroupThread:        canonicalFBID(meta.ThreadKey.ThreadFBID),
+		OtherUser:          canonicalFBID(meta.ThreadKey.OtherUser),
+		Timestamp:          parseMillisTimestamp(meta.Timestamp),
+		OfflineThreadingID: meta.OfflineThreadingID,
+		Tags:               meta.Tags,
+		Mentions:           decodeDeltaMentions(delta.Data.MentionRanges),
+		RepliedToMessageID: delta.Reply.MessageID.ID,
+		FromSession: meta.OfflineThreadingID != "" &&
+			e.session.sentIDs.Contains(meta.OfflineThreadingID),
+		RawDelta: rawDelta,
+	}
+	evt.EphemeralTTL = parseEphemeralTTL(delta.TTL)
+	evt.Expiration = ephemeralExpiration(evt.EphemeralTTL, evt.Timestamp)
+	if repliedTo != nil {
+		var original struct {
+			Body string `json:"body"`
+			Meta struct {
+				MessageID string `json:"messageId"`
+			} `json:"messageMetadata"`
+		}
+		if putJSONIntoObject(repliedTo, &original) == nil {
+			evt.RepliedToSnippet = original.Body
+			if evt.RepliedToMessageID == "" {
+				evt.RepliedToMessageID = original.Meta.MessageID
+			}
+		}
+	}
+	e.emitMessage(evt)
+}
+
+// dispatchClientPayload handles the JSON-encoded deltas
+// embedded in a ClientPayload delta.
+func (e *EventStream) dispatchClientPayload(rawDelta map[string]interface{}) {
+	var delta struct {
+		Payload []int `json:"payload"`
+	}
+	if putJSONIntoObject(rawDelta, &delta) != nil {
+		return
+	}
+	payloadData := make([]byte, len(delta.Payload))
+	for i, b := range delta.Payload {
+		payloadData[i] = byte(b)
+	}
+	var payload struct {
+		Deltas []map[string]interface{} `json:"deltas"`
+	}
+	if json.Unmarshal(payloadData, &payload) != nil {
+		return
+	}
+	for _, inner := range payload.Deltas {
+		reply, ok := inner["deltaMessageReply"].(map[string]interface{})
+		if !ok {
+			e.dispatchUnknown("delta", "ClientPayload", map[string]interface{}{
+				"type":  "delta",
+				"delta": inner,
+			})
+			continue
+		}
+		message, _ := reply["message"].(map[string]interface{})
+		repliedTo, _ := reply["repliedToMessage"].(map[string]interface{})
+		if message == nil {
+			continue
+		}
+		if e.seenDeltas != nil {
+			meta, _ := message["messageMetadata"].(map[string]interface{})
+			messageID, _ := meta["messageId"].(string)
+			id := deltaIdentity("NewMessage", messageID, nil)
+			if id != "" && e.seenDeltas.Add(id) {
+				continue
+			}
+		}
+		e.dispatchNewMessage(message, repliedTo)
+	}
 }
 
 func (e *EventStream) dispatchBuddylistOverlay(obj map[string]interface{}) {
@@ -294,28 +515,39 @@ func (e *EventStream) dispatchBuddylistOverlay(obj map[string]interface{}) {
 
 func (e *EventStream) dispatchTyping(m map[string]interface{}) {
 	var obj struct {
-		State      int     `json:"st"`
-		From       float64 `json:"from"`
-		ThreadFBID float64 `json:"thread_fbid"`
-		Type       string  `json:"type"`
+		State      int         `json:"st"`
+		From       interface{} `json:"from"`
+		ThreadFBID interface{} `json:"thread_fbid"`
+		Type       string      `json:"type"`
 	}
 	if putJSONIntoObject(m, &obj) != nil {
 		return
 	}
 	if obj.Type == "ttyp" {
 		e.emitEvent(TypingEvent{
-			SenderFBID:  floatIDToString(obj.From),
+			SenderFBID:  canonicalFBID(obj.From),
 			Typing:      obj.State == 1,
-			GroupThread: floatIDToString(obj.ThreadFBID),
+			GroupThread: canonicalFBID(obj.ThreadFBID),
 		})
 	} else {
 		e.emitEvent(TypingEvent{
-			SenderFBID: floatIDToString(obj.From),
+			SenderFBID: canonicalFBID(obj.From),
 			Typing:     obj.State == 1,
 		})
 	}
 }
 
+func (e *EventStream) dispatchUnknown(t, class string, m map[string]interface{}) {
+	if !e.options.UnknownEvents {
+		return
+	}
+	e.emitEvent(UnknownEvent{
+		Type:  t,
+		Class: class,
+		Raw:   m,
+	})
+}
+
 func (e *EventStream) checkClosed() bool {
 	select {
 	case <-e.ctx.Done():
@@ -325,6 +557,14 @@ func (e *EventStream) checkClosed() bool {
 	}
 }
 
+func (e *EventStream) emitMessage(msg MessageEvent) {
+	if msg.Timestamp.After(e.lastSeen) {
+		e.lastSeen = msg.Timestamp
+	}
+	e.session.observeMessage(&msg)
+	e.emitEvent(msg)
+}
+
 func (e *EventStream) emitEvent(evt Event) {
 	select {
 	case e.evtChan <- evt:
@@ -332,85 +572,34 @@ func (e *EventStream) emitEvent(evt Event) {
 	}
 }
 
+// markConnected records that the stream's transport is
+// ready to receive events.
+func (e *EventStream) markConnected() {
+	e.connectedOnce.Do(func() {
+		e.session.streamConnected()
+		close(e.connected)
+	})
+}
+
 func (e *EventStream) pollFailed(err error) {
 	e.lock.Lock()
 	e.err = err
 	e.lock.Unlock()
 }
 
-func (e *EventStream) fetchPollingInfo(host string) (stickyPool, stickyToken string, err error) {
-	values := url.Values{}
-	values.Set("cap", "8")
-
-	cbStr := ""
-	e.session.randLock.Lock()
-	for i := 0; i < 4; i++ {
-		cbStr += string(byte(e.session.randGen.Intn(26)) + 'a')
-	}
-	e.session.randLock.Unlock()
-
-	values.Set("cb", cbStr)
-	values.Set("channel", "p_"+e.session.userID)
-	values.Set("clientid", "3342de8f")
-	values.Set("idle", "0")
-	values.Set("msgr_region", "FRC")
-	values.Set("msgs_recv", "0")
-	values.Set("partition", "-2")
-	values.Set("pws", "fresh")
-	values.Set("qp", "y")
-	values.Set("seq", "0")
-	values.Set("state", "offline")
-	values.Set("uid", e.session.userID)
-	values.Set("viewer_uid", e.session.userID)
-	u := "https://0-" + host + ".messenger.com/pull?" + values.Encode()
-	response, err := e.session.jsonForGet(u)
-	if err != nil {
-		return "", "", err
-	}
-	var respObj struct {
-		Type   string `json:"t"`
-		LbInfo *struct {
-			Sticky string `json:"sticky"`
-			Pool   string `json:"pool"`
-		} `json:"lb_info"`
-	}
-	if err := json.Unmarshal(response, &respObj); err != nil {
-		return "", "", errors.New("parse init JSON: " + err.Error())
-	}
-	if respObj.Type == "lb" && respObj.LbInfo != nil {
-		return respObj.LbInfo.Pool, respObj.LbInfo.Sticky, nil
-	}
-	return "", "", errors.New("unexpected initial polling response")
-}
-
-func (e *EventStream) callReconnect() (host string, err error) {
-	values, err := e.session.commonParams()
-	if err != nil {
-		return "", err
-	}
-	values.Set("reason", "6")
-	u := "https://www.messenger.com/ajax/presence/reconnect.php?" + values.Encode()
-	response, err := e.session.jsonForGet(u)
-	if err != nil {
-		return "", err
-	}
-
-	var respObj struct {
-		Payload struct {
-			Host string `json:"host"`
-		} `json:"payload"`
-	}
-	if err := json.Unmarshal(response, &respObj); err != nil {
-		return "", err
-	}
-	return respObj.Payload.Host, nil
-}
-
 // EventStream creates a new EventStream for the session.
 //
 // You must close the result when you are done with it.
 func (s *Session) EventStream() *EventStream {
-	return newEventStream(s, false)
+	return newEventStream(s, nil, false)
+}
+
+// EventStreamWithOptions is like EventStream, but allows
+// the caller to customize the stream.
+//
+// If opts is nil, the default options are used.
+func (s *Session) EventStreamWithOptions(opts *StreamOptions) *EventStream {
+	return newEventStream(s, opts, false)
 }
 
 // ReadEvent reads the next event from a default event
@@ -448,36 +637,55 @@ func (s *Session) ReadEvent() (Event, error) {
 //
 // This closes the default event stream, meaning that all
 // ReadEvent calls will fail after Close() is finished.
+// It also stops sends which are waiting for the rate limit.
 func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+
 	s.defaultStreamLock.Lock()
 	if s.defaultStream != nil {
 		s.defaultStream.Close()
 	} else {
-		s.defaultStream = newEventStream(s, true)
+		s.defaultStream = newEventStream(s, nil, true)
 	}
 	s.defaultStreamLock.Unlock()
 	return nil
 }
 
-// parseMessages extracts all of the "msg" payloads from a
-// polled event body.
-func parseMessages(data []byte) (list []map[string]interface{}, newSeq int, err error) {
-	reader := json.NewDecoder(bytes.NewBuffer(data))
-	for reader.More() {
-		var objVal struct {
-			Type     string                   `json:"t"`
-			Seq      int                      `json:"seq"`
-			Messages []map[string]interface{} `json:"ms"`
-		}
-		if err := reader.Decode(&objVal); err != nil {
-			return nil, 0, err
-		}
-		if objVal.Seq > newSeq {
-			newSeq = objVal.Seq
-		}
-		if objVal.Type == "msg" {
-			list = append(list, objVal.Messages...)
-		}
+// decodeDeltaMentions decodes the JSON-encoded mention
+// ranges from a message delta.
+func decodeDeltaMentions(ranges string) []Mention {
+	if ranges == "" {
+		return nil
+	}
+	var rawRanges []struct {
+		ID     interface{} `json:"i"`
+		Offset int         `json:"o"`
+		Length int         `json:"l"`
+	}
+	if json.Unmarshal([]byte(ranges), &rawRanges) != nil {
+		return nil
+	}
+	var res []Mention
+	for _, r := range rawRanges {
+		res = append(res, Mention{
+			FBID:   canonicalFBID(r.ID),
+			Offset: r.Offset,
+			Length: r.Length,
+		})
+	}
+	return res
+}
+
+// deltaIdentity computes a string which uniquely
+// identifies a delta.
+// It returns "" if the delta cannot be identified.
+func deltaIdentity(class, messageID string, messageIDs []string) string {
+	if messageID != "" {
+		return class + ":" + messageID
+	} else if len(messageIDs) > 0 {
+		return class + ":" + strings.Join(messageIDs, ",")
 	}
-	return
+	return ""
 }
@@ -0,0 +1,165 @@
+//go:build ignore
+// +build ignore
+
+// Command gen_like_emojis generates like_emojis.go from
+// the Unicode emoji test data.
+//
+// Only single emojis, optionally with a skin tone, are
+// included.
+// ZWJ, flag, keycap and tag sequences are left out, as are
+// emojis newer than MaxEmojiVersion, since Messenger does
+// not render them as hot likes.
+//
+// Usage:
+//
+//	go run gen_like_emojis.go [emoji-test.txt]
+//
+// If no path is given, the file is downloaded from
+// unicode.org.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	EmojiVersion = "15.1"
+	EmojiTestURL = "https://unicode.org/Public/emoji/" + EmojiVersion + "/emoji-test.txt"
+	OutputFile   = "like_emojis.go"
+
+	// MaxEmojiVersion is the newest emoji version that
+	// Messenger is known to support.
+	MaxEmojiVersion = 13.0
+
+	PerLine = 10
+)
+
+func main() {
+	var r io.ReadCloser
+	if len(os.Args) > 1 {
+		f, err := os.Open(os.Args[1])
+		must(err)
+		r = f
+	} else {
+		resp, err := http.Get(EmojiTestURL)
+		must(err)
+		if resp.StatusCode != http.StatusOK {
+			must(fmt.Errorf("unexpected status: %s", resp.Status))
+		}
+		r = resp.Body
+	}
+	defer r.Close()
+
+	var out bytes.Buffer
+	fmt.Fprintln(&out, "// Code generated by gen_like_emojis.go; DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "package fbmsgr")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "// hotLikeEmojis contains the fully-qualified emojis")
+	fmt.Fprintln(&out, "// listed in the Unicode "+EmojiVersion+" emoji test data:")
+	fmt.Fprintln(&out, "// "+EmojiTestURL)
+	fmt.Fprintln(&out, "//")
+	fmt.Fprintf(&out, "// Only emojis up to version %.1f are included, and ZWJ,\n",
+		MaxEmojiVersion)
+	fmt.Fprintln(&out, "// flag, keycap and tag sequences are left out.")
+	fmt.Fprintln(&out, "// Variation selectors are removed from the keys.")
+	fmt.Fprintln(&out, "var hotLikeEmojis = stringSet(")
+
+	seen := map[string]bool{}
+	var group string
+	var line []string
+	flush := func() {
+		if len(line) > 0 {
+			fmt.Fprintln(&out, strings.Join(line, ", ")+",")
+			line = nil
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "# group: ") {
+			group = strings.TrimPrefix(text, "# group: ")
+			continue
+		}
+		parts := strings.SplitN(text, "#", 2)
+		fields := strings.SplitN(parts[0], ";", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[1]) != "fully-qualified" {
+			continue
+		}
+		if emojiVersion(parts[1]) > MaxEmojiVersion {
+			continue
+		}
+		var emoji string
+		var isSequence bool
+		for _, code := range strings.Fields(fields[0]) {
+			n, err := strconv.ParseUint(code, 16, 32)
+			must(err)
+			if isSequenceCode(rune(n)) {
+				isSequence = true
+			} else if n != 0xfe0f {
+				emoji += string(rune(n))
+			}
+		}
+		if isSequence || seen[emoji] {
+			continue
+		}
+		seen[emoji] = true
+		if group != "" {
+			flush()
+			if len(seen) > 1 {
+				fmt.Fprintln(&out)
+			}
+			fmt.Fprintln(&out, "// "+group)
+			group = ""
+		}
+		line = append(line, strconv.Quote(emoji))
+		if len(line) == PerLine {
+			flush()
+		}
+	}
+	must(scanner.Err())
+	flush()
+	fmt.Fprintln(&out, ")")
+
+	source, err := format.Source(out.Bytes())
+	must(err)
+	must(ioutil.WriteFile(OutputFile, source, 0644))
+}
+
+// emojiVersion parses the version from a comment such as
+// "😀 E1.0 grinning face".
+func emojiVersion(comment string) float64 {
+	for _, field := range strings.Fields(comment) {
+		if strings.HasPrefix(field, "E") {
+			if v, err := strconv.ParseFloat(field[1:], 64); err == nil {
+				return v
+			}
+		}
+	}
+	must(fmt.Errorf("missing emoji version: %s", comment))
+	return 0
+}
+
+// isSequenceCode checks if a code point only appears in
+// ZWJ, flag, keycap or tag sequences.
+func isSequenceCode(r rune) bool {
+	return r == 0x200d || r == 0x20e3 ||
+		(r >= 0x1f1e6 && r <= 0x1f1ff) ||
+		(r >= 0xe0020 && r <= 0xe007f)
+}
+
+func must(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
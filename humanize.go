@@ -0,0 +1,106 @@
+package fbmsgr
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+const (
+	defaultHumanizeWPM      = 40
+	defaultHumanizeJitter   = 0.2
+	defaultHumanizeMaxDelay = time.Second * 15
+)
+
+// HumanizeOptions controls how SendTextHumanized simulates
+// a person typing.
+type HumanizeOptions struct {
+	// WordsPerMinute is the simulated typing speed.
+	// If 0, a default of 40 is used.
+	WordsPerMinute float64
+
+	// Jitter is the maximum fraction by which the typing
+	// time is randomly shortened or lengthened.
+	// If 0, a default of 0.2 is used.
+	// If negative, the typing time is not randomized.
+	Jitter float64
+
+	// MaxDelay limits the typing time for long messages.
+	// If 0, a default of 15 seconds is used.
+	MaxDelay time.Duration
+}
+
+// SendTextHumanized sends a textual message after showing
+// a typing indicator for a time proportional to the length
+// of the message.
+//
+// If ctx is cancelled or an error occurs before the
+// message is sent, the typing indicator is turned off.
+// If opts is nil, the default options are used.
+func (s *Session) SendTextHumanized(ctx context.Context, thread ThreadID, message string,
+	opts *HumanizeOptions) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send text humanized", &err)
+
+	if err := s.setTyping(thread, true); err != nil {
+		return "", err
+	}
+
+	timer := time.NewTimer(s.typingDuration(message, opts))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		s.setTyping(thread, false)
+		return "", ctx.Err()
+	}
+
+	msgID, err = s.sendContext(ctx, thread, &Message{Body: message})
+	if err != nil {
+		s.setTyping(thread, false)
+		return "", err
+	}
+	return msgID, nil
+}
+
+func (s *Session) setTyping(thread ThreadID, typing bool) error {
+	if thread.Group {
+		return s.sendTyping(thread.FBID, "", typing)
+	}
+	return s.sendTyping(thread.FBID, thread.FBID, typing)
+}
+
+func (s *Session) typingDuration(message string, opts *HumanizeOptions) time.Duration {
+	if opts == nil {
+		opts = &HumanizeOptions{}
+	}
+	wpm := opts.WordsPerMinute
+	if wpm <= 0 {
+		wpm = defaultHumanizeWPM
+	}
+	jitter := opts.Jitter
+	if jitter == 0 {
+		jitter = defaultHumanizeJitter
+	}
+	maxDelay := opts.MaxDelay
+	if maxDelay == 0 {
+		maxDelay = defaultHumanizeMaxDelay
+	}
+
+	words := len(strings.Fields(message))
+	if words == 0 {
+		words = 1
+	}
+	minutes := float64(words) / wpm
+	if jitter > 0 {
+		s.randLock.Lock()
+		minutes *= 1 + jitter*(2*s.randGen.Float64()-1)
+		s.randLock.Unlock()
+	}
+	res := time.Duration(minutes * float64(time.Minute))
+	if res > maxDelay {
+		res = maxDelay
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package fbmsgr
+
+import (
+	"strings"
+)
+
+const (
+	hotLikeSourceTag = "hot_emoji_source:hot_like"
+	hotLikeSizeTag   = "hot_emoji_size:"
+)
+
+//go:generate go run gen_like_emojis.go
+
+// An InvalidLikeError is returned when a hot-like message
+// has an emoji or size that Messenger does not accept.
+//
+// Sending such a message can trigger a bug in the web
+// client that essentially bricks the conversation, so it
+// is refused before it reaches the server.
+type InvalidLikeError struct {
+	Emoji string
+	Size  EmojiSize
+}
+
+// Error returns a description of the invalid field.
+func (i *InvalidLikeError) Error() string {
+	if !IsLikeEmoji(i.Emoji) {
+		return "invalid like emoji: " + i.Emoji
+	}
+	return "invalid like emoji size: " + string(i.Size)
+}
+
+// IsLikeEmoji checks if Messenger accepts an emoji for
+// SendLike.
+func IsLikeEmoji(emoji string) bool {
+	return hotLikeEmojis[strings.Replace(emoji, "\ufe0f", "", -1)]
+}
+
+// Valid checks if the size is one of the sizes Messenger
+// accepts.
+func (e EmojiSize) Valid() bool {
+	return e == SmallEmoji || e == MediumEmoji || e == LargeEmoji
+}
+
+// checkHotLike validates a message if it is tagged as a
+// hot-like message.
+func checkHotLike(msg *Message) error {
+	var isLike bool
+	var size EmojiSize
+	var hasSize bool
+	for _, tag := range msg.Tags {
+		if tag == hotLikeSourceTag {
+			isLike = true
+		} else if strings.HasPrefix(tag, hotLikeSizeTag) {
+			size = EmojiSize(strings.TrimPrefix(tag, hotLikeSizeTag))
+			hasSize = true
+		}
+	}
+	if !isLike && !hasSize {
+		return nil
+	}
+	if !IsLikeEmoji(msg.Body) || !size.Valid() {
+		return &InvalidLikeError{Emoji: msg.Body, Size: size}
+	}
+	return nil
+}
+
+func stringSet(strs ...string) map[string]bool {
+	res := map[string]bool{}
+	for _, s := range strs {
+		res[s] = true
+	}
+	return res
+}
@@ -0,0 +1,224 @@
+// Code generated by gen_like_emojis.go; DO NOT EDIT.
+
+package fbmsgr
+
+// hotLikeEmojis contains the fully-qualified emojis
+// listed in the Unicode 15.1 emoji test data:
+// https://unicode.org/Public/emoji/15.1/emoji-test.txt
+//
+// Only emojis up to version 13.0 are included, and ZWJ,
+// flag, keycap and tag sequences are left out.
+// Variation selectors are removed from the keys.
+var hotLikeEmojis = stringSet(
+	// Smileys & Emotion
+	"😀", "😃", "😄", "😁", "😆", "😅", "🤣", "😂", "🙂", "🙃",
+	"😉", "😊", "😇", "🥰", "😍", "🤩", "😘", "😗", "☺", "😚",
+	"😙", "🥲", "😋", "😛", "😜", "🤪", "😝", "🤑", "🤗", "🤭",
+	"🤫", "🤔", "🤐", "🤨", "😐", "😑", "😶", "😏", "😒", "🙄",
+	"😬", "🤥", "😌", "😔", "😪", "🤤", "😴", "😷", "🤒", "🤕",
+	"🤢", "🤮", "🤧", "🥵", "🥶", "🥴", "😵", "🤯", "🤠", "🥳",
+	"🥸", "😎", "🤓", "🧐", "😕", "😟", "🙁", "☹", "😮", "😯",
+	"😲", "😳", "🥺", "😦", "😧", "😨", "😰", "😥", "😢", "😭",
+	"😱", "😖", "😣", "😞", "😓", "😩", "😫", "🥱", "😤", "😡",
+	"😠", "🤬", "😈", "👿", "💀", "☠", "💩", "🤡", "👹", "👺",
+	"👻", "👽", "👾", "🤖", "😺", "😸", "😹", "😻", "😼", "😽",
+	"🙀", "😿", "😾", "🙈", "🙉", "🙊", "💌", "💘", "💝", "💖",
+	"💗", "💓", "💞", "💕", "💟", "❣", "💔", "❤", "🧡", "💛",
+	"💚", "💙", "💜", "🤎", "🖤", "🤍", "💋", "💯", "💢", "💥",
+	"💫", "💦", "💨", "🕳", "💬", "🗨", "🗯", "💭", "💤",
+
+	// People & Body
+	"👋", "👋🏻", "👋🏼", "👋🏽", "👋🏾", "👋🏿", "🤚", "🤚🏻", "🤚🏼", "🤚🏽",
+	"🤚🏾", "🤚🏿", "🖐", "🖐🏻", "🖐🏼", "🖐🏽", "🖐🏾", "🖐🏿", "✋", "✋🏻",
+	"✋🏼", "✋🏽", "✋🏾", "✋🏿", "🖖", "🖖🏻", "🖖🏼", "🖖🏽", "🖖🏾", "🖖🏿",
+	"👌", "👌🏻", "👌🏼", "👌🏽", "👌🏾", "👌🏿", "🤌", "🤌🏻", "🤌🏼", "🤌🏽",
+	"🤌🏾", "🤌🏿", "🤏", "🤏🏻", "🤏🏼", "🤏🏽", "🤏🏾", "🤏🏿", "✌", "✌🏻",
+	"✌🏼", "✌🏽", "✌🏾", "✌🏿", "🤞", "🤞🏻", "🤞🏼", "🤞🏽", "🤞🏾", "🤞🏿",
+	"🤟", "🤟🏻", "🤟🏼", "🤟🏽", "🤟🏾", "🤟🏿", "🤘", "🤘🏻", "🤘🏼", "🤘🏽",
+	"🤘🏾", "🤘🏿", "🤙", "🤙🏻", "🤙🏼", "🤙🏽", "🤙🏾", "🤙🏿", "👈", "👈🏻",
+	"👈🏼", "👈🏽", "👈🏾", "👈🏿", "👉", "👉🏻", "👉🏼", "👉🏽", "👉🏾", "👉🏿",
+	"👆", "👆🏻", "👆🏼", "👆🏽", "👆🏾", "👆🏿", "🖕", "🖕🏻", "🖕🏼", "🖕🏽",
+	"🖕🏾", "🖕🏿", "👇", "👇🏻", "👇🏼", "👇🏽", "👇🏾", "👇🏿", "☝", "☝🏻",
+	"☝🏼", "☝🏽", "☝🏾", "☝🏿", "👍", "👍🏻", "👍🏼", "👍🏽", "👍🏾", "👍🏿",
+	"👎", "👎🏻", "👎🏼", "👎🏽", "👎🏾", "👎🏿", "✊", "✊🏻", "✊🏼", "✊🏽",
+	"✊🏾", "✊🏿", "👊", "👊🏻", "👊🏼", "👊🏽", "👊🏾", "👊🏿", "🤛", "🤛🏻",
+	"🤛🏼", "🤛🏽", "🤛🏾", "🤛🏿", "🤜", "🤜🏻", "🤜🏼", "🤜🏽", "🤜🏾", "🤜🏿",
+	"👏", "👏🏻", "👏🏼", "👏🏽", "👏🏾", "👏🏿", "🙌", "🙌🏻", "🙌🏼", "🙌🏽",
+	"🙌🏾", "🙌🏿", "👐", "👐🏻", "👐🏼", "👐🏽", "👐🏾", "👐🏿", "🤲", "🤲🏻",
+	"🤲🏼", "🤲🏽", "🤲🏾", "🤲🏿", "🤝", "🙏", "🙏🏻", "🙏🏼", "🙏🏽", "🙏🏾",
+	"🙏🏿", "✍", "✍🏻", "✍🏼", "✍🏽", "✍🏾", "✍🏿", "💅", "💅🏻", "💅🏼",
+	"💅🏽", "💅🏾", "💅🏿", "🤳", "🤳🏻", "🤳🏼", "🤳🏽", "🤳🏾", "🤳🏿", "💪",
+	"💪🏻", "💪🏼", "💪🏽", "💪🏾", "💪🏿", "🦾", "🦿", "🦵", "🦵🏻", "🦵🏼",
+	"🦵🏽", "🦵🏾", "🦵🏿", "🦶", "🦶🏻", "🦶🏼", "🦶🏽", "🦶🏾", "🦶🏿", "👂",
+	"👂🏻", "👂🏼", "👂🏽", "👂🏾", "👂🏿", "🦻", "🦻🏻", "🦻🏼", "🦻🏽", "🦻🏾",
+	"🦻🏿", "👃", "👃🏻", "👃🏼", "👃🏽", "👃🏾", "👃🏿", "🧠", "🫀", "🫁",
+	"🦷", "🦴", "👀", "👁", "👅", "👄", "👶", "👶🏻", "👶🏼", "👶🏽",
+	"👶🏾", "👶🏿", "🧒", "🧒🏻", "🧒🏼", "🧒🏽", "🧒🏾", "🧒🏿", "👦", "👦🏻",
+	"👦🏼", "👦🏽", "👦🏾", "👦🏿", "👧", "👧🏻", "👧🏼", "👧🏽", "👧🏾", "👧🏿",
+	"🧑", "🧑🏻", "🧑🏼", "🧑🏽", "🧑🏾", "🧑🏿", "👱", "👱🏻", "👱🏼", "👱🏽",
+	"👱🏾", "👱🏿", "👨", "👨🏻", "👨🏼", "👨🏽", "👨🏾", "👨🏿", "🧔", "🧔🏻",
+	"🧔🏼", "🧔🏽", "🧔🏾", "🧔🏿", "👩", "👩🏻", "👩🏼", "👩🏽", "👩🏾", "👩🏿",
+	"🧓", "🧓🏻", "🧓🏼", "🧓🏽", "🧓🏾", "🧓🏿", "👴", "👴🏻", "👴🏼", "👴🏽",
+	"👴🏾", "👴🏿", "👵", "👵🏻", "👵🏼", "👵🏽", "👵🏾", "👵🏿", "🙍", "🙍🏻",
+	"🙍🏼", "🙍🏽", "🙍🏾", "🙍🏿", "🙎", "🙎🏻", "🙎🏼", "🙎🏽", "🙎🏾", "🙎🏿",
+	"🙅", "🙅🏻", "🙅🏼", "🙅🏽", "🙅🏾", "🙅🏿", "🙆", "🙆🏻", "🙆🏼", "🙆🏽",
+	"🙆🏾", "🙆🏿", "💁", "💁🏻", "💁🏼", "💁🏽", "💁🏾", "💁🏿", "🙋", "🙋🏻",
+	"🙋🏼", "🙋🏽", "🙋🏾", "🙋🏿", "🧏", "🧏🏻", "🧏🏼", "🧏🏽", "🧏🏾", "🧏🏿",
+	"🙇", "🙇🏻", "🙇🏼", "🙇🏽", "🙇🏾", "🙇🏿", "🤦", "🤦🏻", "🤦🏼", "🤦🏽",
+	"🤦🏾", "🤦🏿", "🤷", "🤷🏻", "🤷🏼", "🤷🏽", "🤷🏾", "🤷🏿", "👮", "👮🏻",
+	"👮🏼", "👮🏽", "👮🏾", "👮🏿", "🕵", "🕵🏻", "🕵🏼", "🕵🏽", "🕵🏾", "🕵🏿",
+	"💂", "💂🏻", "💂🏼", "💂🏽", "💂🏾", "💂🏿", "🥷", "🥷🏻", "🥷🏼", "🥷🏽",
+	"🥷🏾", "🥷🏿", "👷", "👷🏻", "👷🏼", "👷🏽", "👷🏾", "👷🏿", "🤴", "🤴🏻",
+	"🤴🏼", "🤴🏽", "🤴🏾", "🤴🏿", "👸", "👸🏻", "👸🏼", "👸🏽", "👸🏾", "👸🏿",
+	"👳", "👳🏻", "👳🏼", "👳🏽", "👳🏾", "👳🏿", "👲", "👲🏻", "👲🏼", "👲🏽",
+	"👲🏾", "👲🏿", "🧕", "🧕🏻", "🧕🏼", "🧕🏽", "🧕🏾", "🧕🏿", "🤵", "🤵🏻",
+	"🤵🏼", "🤵🏽", "🤵🏾", "🤵🏿", "👰", "👰🏻", "👰🏼", "👰🏽", "👰🏾", "👰🏿",
+	"🤰", "🤰🏻", "🤰🏼", "🤰🏽", "🤰🏾", "🤰🏿", "🤱", "🤱🏻", "🤱🏼", "🤱🏽",
+	"🤱🏾", "🤱🏿", "👼", "👼🏻", "👼🏼", "👼🏽", "👼🏾", "👼🏿", "🎅", "🎅🏻",
+	"🎅🏼", "🎅🏽", "🎅🏾", "🎅🏿", "🤶", "🤶🏻", "🤶🏼", "🤶🏽", "🤶🏾", "🤶🏿",
+	"🦸", "🦸🏻", "🦸🏼", "🦸🏽", "🦸🏾", "🦸🏿", "🦹", "🦹🏻", "🦹🏼", "🦹🏽",
+	"🦹🏾", "🦹🏿", "🧙", "🧙🏻", "🧙🏼", "🧙🏽", "🧙🏾", "🧙🏿", "🧚", "🧚🏻",
+	"🧚🏼", "🧚🏽", "🧚🏾", "🧚🏿", "🧛", "🧛🏻", "🧛🏼", "🧛🏽", "🧛🏾", "🧛🏿",
+	"🧜", "🧜🏻", "🧜🏼", "🧜🏽", "🧜🏾", "🧜🏿", "🧝", "🧝🏻", "🧝🏼", "🧝🏽",
+	"🧝🏾", "🧝🏿", "🧞", "🧟", "💆", "💆🏻", "💆🏼", "💆🏽", "💆🏾", "💆🏿",
+	"💇", "💇🏻", "💇🏼", "💇🏽", "💇🏾", "💇🏿", "🚶", "🚶🏻", "🚶🏼", "🚶🏽",
+	"🚶🏾", "🚶🏿", "🧍", "🧍🏻", "🧍🏼", "🧍🏽", "🧍🏾", "🧍🏿", "🧎", "🧎🏻",
+	"🧎🏼", "🧎🏽", "🧎🏾", "🧎🏿", "🏃", "🏃🏻", "🏃🏼", "🏃🏽", "🏃🏾", "🏃🏿",
+	"💃", "💃🏻", "💃🏼", "💃🏽", "💃🏾", "💃🏿", "🕺", "🕺🏻", "🕺🏼", "🕺🏽",
+	"🕺🏾", "🕺🏿", "🕴", "🕴🏻", "🕴🏼", "🕴🏽", "🕴🏾", "🕴🏿", "👯", "🧖",
+	"🧖🏻", "🧖🏼", "🧖🏽", "🧖🏾", "🧖🏿", "🧗", "🧗🏻", "🧗🏼", "🧗🏽", "🧗🏾",
+	"🧗🏿", "🤺", "🏇", "🏇🏻", "🏇🏼", "🏇🏽", "🏇🏾", "🏇🏿", "⛷", "🏂",
+	"🏂🏻", "🏂🏼", "🏂🏽", "🏂🏾", "🏂🏿", "🏌", "🏌🏻", "🏌🏼", "🏌🏽", "🏌🏾",
+	"🏌🏿", "🏄", "🏄🏻", "🏄🏼", "🏄🏽", "🏄🏾", "🏄🏿", "🚣", "🚣🏻", "🚣🏼",
+	"🚣🏽", "🚣🏾", "🚣🏿", "🏊", "🏊🏻", "🏊🏼", "🏊🏽", "🏊🏾", "🏊🏿", "⛹",
+	"⛹🏻", "⛹🏼", "⛹🏽", "⛹🏾", "⛹🏿", "🏋", "🏋🏻", "🏋🏼", "🏋🏽", "🏋🏾",
+	"🏋🏿", "🚴", "🚴🏻", "🚴🏼", "🚴🏽", "🚴🏾", "🚴🏿", "🚵", "🚵🏻", "🚵🏼",
+	"🚵🏽", "🚵🏾", "🚵🏿", "🤸", "🤸🏻", "🤸🏼", "🤸🏽", "🤸🏾", "🤸🏿", "🤼",
+	"🤽", "🤽🏻", "🤽🏼", "🤽🏽", "🤽🏾", "🤽🏿", "🤾", "🤾🏻", "🤾🏼", "🤾🏽",
+	"🤾🏾", "🤾🏿", "🤹", "🤹🏻", "🤹🏼", "🤹🏽", "🤹🏾", "🤹🏿", "🧘", "🧘🏻",
+	"🧘🏼", "🧘🏽", "🧘🏾", "🧘🏿", "🛀", "🛀🏻", "🛀🏼", "🛀🏽", "🛀🏾", "🛀🏿",
+	"🛌", "🛌🏻", "🛌🏼", "🛌🏽", "🛌🏾", "🛌🏿", "👭", "👭🏻", "👭🏼", "👭🏽",
+	"👭🏾", "👭🏿", "👫", "👫🏻", "👫🏼", "👫🏽", "👫🏾", "👫🏿", "👬", "👬🏻",
+	"👬🏼", "👬🏽", "👬🏾", "👬🏿", "💏", "💑", "🗣", "👤", "👥", "🫂",
+	"👪", "👣",
+
+	// Animals & Nature
+	"🐵", "🐒", "🦍", "🦧", "🐶", "🐕", "🦮", "🐩", "🐺", "🦊",
+	"🦝", "🐱", "🐈", "🦁", "🐯", "🐅", "🐆", "🐴", "🐎", "🦄",
+	"🦓", "🦌", "🦬", "🐮", "🐂", "🐃", "🐄", "🐷", "🐖", "🐗",
+	"🐽", "🐏", "🐑", "🐐", "🐪", "🐫", "🦙", "🦒", "🐘", "🦣",
+	"🦏", "🦛", "🐭", "🐁", "🐀", "🐹", "🐰", "🐇", "🐿", "🦫",
+	"🦔", "🦇", "🐻", "🐨", "🐼", "🦥", "🦦", "🦨", "🦘", "🦡",
+	"🐾", "🦃", "🐔", "🐓", "🐣", "🐤", "🐥", "🐦", "🐧", "🕊",
+	"🦅", "🦆", "🦢", "🦉", "🦤", "🪶", "🦩", "🦚", "🦜", "🐸",
+	"🐊", "🐢", "🦎", "🐍", "🐲", "🐉", "🦕", "🦖", "🐳", "🐋",
+	"🐬", "🦭", "🐟", "🐠", "🐡", "🦈", "🐙", "🐚", "🐌", "🦋",
+	"🐛", "🐜", "🐝", "🪲", "🐞", "🦗", "🪳", "🕷", "🕸", "🦂",
+	"🦟", "🪰", "🪱", "🦠", "💐", "🌸", "💮", "🏵", "🌹", "🥀",
+	"🌺", "🌻", "🌼", "🌷", "🌱", "🪴", "🌲", "🌳", "🌴", "🌵",
+	"🌾", "🌿", "☘", "🍀", "🍁", "🍂", "🍃", "🍄",
+
+	// Food & Drink
+	"🍇", "🍈", "🍉", "🍊", "🍋", "🍌", "🍍", "🥭", "🍎", "🍏",
+	"🍐", "🍑", "🍒", "🍓", "🫐", "🥝", "🍅", "🫒", "🥥", "🥑",
+	"🍆", "🥔", "🥕", "🌽", "🌶", "🫑", "🥒", "🥬", "🥦", "🧄",
+	"🧅", "🥜", "🌰", "🍞", "🥐", "🥖", "🫓", "🥨", "🥯", "🥞",
+	"🧇", "🧀", "🍖", "🍗", "🥩", "🥓", "🍔", "🍟", "🍕", "🌭",
+	"🥪", "🌮", "🌯", "🫔", "🥙", "🧆", "🥚", "🍳", "🥘", "🍲",
+	"🫕", "🥣", "🥗", "🍿", "🧈", "🧂", "🥫", "🍱", "🍘", "🍙",
+	"🍚", "🍛", "🍜", "🍝", "🍠", "🍢", "🍣", "🍤", "🍥", "🥮",
+	"🍡", "🥟", "🥠", "🥡", "🦀", "🦞", "🦐", "🦑", "🦪", "🍦",
+	"🍧", "🍨", "🍩", "🍪", "🎂", "🍰", "🧁", "🥧", "🍫", "🍬",
+	"🍭", "🍮", "🍯", "🍼", "🥛", "☕", "🫖", "🍵", "🍶", "🍾",
+	"🍷", "🍸", "🍹", "🍺", "🍻", "🥂", "🥃", "🥤", "🧋", "🧃",
+	"🧉", "🧊", "🥢", "🍽", "🍴", "🥄", "🔪", "🏺",
+
+	// Travel & Places
+	"🌍", "🌎", "🌏", "🌐", "🗺", "🗾", "🧭", "🏔", "⛰", "🌋",
+	"🗻", "🏕", "🏖", "🏜", "🏝", "🏞", "🏟", "🏛", "🏗", "🧱",
+	"🪨", "🪵", "🛖", "🏘", "🏚", "🏠", "🏡", "🏢", "🏣", "🏤",
+	"🏥", "🏦", "🏨", "🏩", "🏪", "🏫", "🏬", "🏭", "🏯", "🏰",
+	"💒", "🗼", "🗽", "⛪", "🕌", "🛕", "🕍", "⛩", "🕋", "⛲",
+	"⛺", "🌁", "🌃", "🏙", "🌄", "🌅", "🌆", "🌇", "🌉", "♨",
+	"🎠", "🎡", "🎢", "💈", "🎪", "🚂", "🚃", "🚄", "🚅", "🚆",
+	"🚇", "🚈", "🚉", "🚊", "🚝", "🚞", "🚋", "🚌", "🚍", "🚎",
+	"🚐", "🚑", "🚒", "🚓", "🚔", "🚕", "🚖", "🚗", "🚘", "🚙",
+	"🛻", "🚚", "🚛", "🚜", "🏎", "🏍", "🛵", "🦽", "🦼", "🛺",
+	"🚲", "🛴", "🛹", "🛼", "🚏", "🛣", "🛤", "🛢", "⛽", "🚨",
+	"🚥", "🚦", "🛑", "🚧", "⚓", "⛵", "🛶", "🚤", "🛳", "⛴",
+	"🛥", "🚢", "✈", "🛩", "🛫", "🛬", "🪂", "💺", "🚁", "🚟",
+	"🚠", "🚡", "🛰", "🚀", "🛸", "🛎", "🧳", "⌛", "⏳", "⌚",
+	"⏰", "⏱", "⏲", "🕰", "🕛", "🕧", "🕐", "🕜", "🕑", "🕝",
+	"🕒", "🕞", "🕓", "🕟", "🕔", "🕠", "🕕", "🕡", "🕖", "🕢",
+	"🕗", "🕣", "🕘", "🕤", "🕙", "🕥", "🕚", "🕦", "🌑", "🌒",
+	"🌓", "🌔", "🌕", "🌖", "🌗", "🌘", "🌙", "🌚", "🌛", "🌜",
+	"🌡", "☀", "🌝", "🌞", "🪐", "⭐", "🌟", "🌠", "🌌", "☁",
+	"⛅", "⛈", "🌤", "🌥", "🌦", "🌧", "🌨", "🌩", "🌪", "🌫",
+	"🌬", "🌀", "🌈", "🌂", "☂", "☔", "⛱", "⚡", "❄", "☃",
+	"⛄", "☄", "🔥", "💧", "🌊",
+
+	// Activities
+	"🎃", "🎄", "🎆", "🎇", "🧨", "✨", "🎈", "🎉", "🎊", "🎋",
+	"🎍", "🎎", "🎏", "🎐", "🎑", "🧧", "🎀", "🎁", "🎗", "🎟",
+	"🎫", "🎖", "🏆", "🏅", "🥇", "🥈", "🥉", "⚽", "⚾", "🥎",
+	"🏀", "🏐", "🏈", "🏉", "🎾", "🥏", "🎳", "🏏", "🏑", "🏒",
+	"🥍", "🏓", "🏸", "🥊", "🥋", "🥅", "⛳", "⛸", "🎣", "🤿",
+	"🎽", "🎿", "🛷", "🥌", "🎯", "🪀", "🪁", "🔫", "🎱", "🔮",
+	"🪄", "🎮", "🕹", "🎰", "🎲", "🧩", "🧸", "🪅", "🪆", "♠",
+	"♥", "♦", "♣", "♟", "🃏", "🀄", "🎴", "🎭", "🖼", "🎨",
+	"🧵", "🪡", "🧶", "🪢",
+
+	// Objects
+	"👓", "🕶", "🥽", "🥼", "🦺", "👔", "👕", "👖", "🧣", "🧤",
+	"🧥", "🧦", "👗", "👘", "🥻", "🩱", "🩲", "🩳", "👙", "👚",
+	"👛", "👜", "👝", "🛍", "🎒", "🩴", "👞", "👟", "🥾", "🥿",
+	"👠", "👡", "🩰", "👢", "👑", "👒", "🎩", "🎓", "🧢", "🪖",
+	"⛑", "📿", "💄", "💍", "💎", "🔇", "🔈", "🔉", "🔊", "📢",
+	"📣", "📯", "🔔", "🔕", "🎼", "🎵", "🎶", "🎙", "🎚", "🎛",
+	"🎤", "🎧", "📻", "🎷", "🪗", "🎸", "🎹", "🎺", "🎻", "🪕",
+	"🥁", "🪘", "📱", "📲", "☎", "📞", "📟", "📠", "🔋", "🔌",
+	"💻", "🖥", "🖨", "⌨", "🖱", "🖲", "💽", "💾", "💿", "📀",
+	"🧮", "🎥", "🎞", "📽", "🎬", "📺", "📷", "📸", "📹", "📼",
+	"🔍", "🔎", "🕯", "💡", "🔦", "🏮", "🪔", "📔", "📕", "📖",
+	"📗", "📘", "📙", "📚", "📓", "📒", "📃", "📜", "📄", "📰",
+	"🗞", "📑", "🔖", "🏷", "💰", "🪙", "💴", "💵", "💶", "💷",
+	"💸", "💳", "🧾", "💹", "✉", "📧", "📨", "📩", "📤", "📥",
+	"📦", "📫", "📪", "📬", "📭", "📮", "🗳", "✏", "✒", "🖋",
+	"🖊", "🖌", "🖍", "📝", "💼", "📁", "📂", "🗂", "📅", "📆",
+	"🗒", "🗓", "📇", "📈", "📉", "📊", "📋", "📌", "📍", "📎",
+	"🖇", "📏", "📐", "✂", "🗃", "🗄", "🗑", "🔒", "🔓", "🔏",
+	"🔐", "🔑", "🗝", "🔨", "🪓", "⛏", "⚒", "🛠", "🗡", "⚔",
+	"💣", "🪃", "🏹", "🛡", "🪚", "🔧", "🪛", "🔩", "⚙", "🗜",
+	"⚖", "🦯", "🔗", "⛓", "🪝", "🧰", "🧲", "🪜", "⚗", "🧪",
+	"🧫", "🧬", "🔬", "🔭", "📡", "💉", "🩸", "💊", "🩹", "🩺",
+	"🚪", "🛗", "🪞", "🪟", "🛏", "🛋", "🪑", "🚽", "🪠", "🚿",
+	"🛁", "🪤", "🪒", "🧴", "🧷", "🧹", "🧺", "🧻", "🪣", "🧼",
+	"🪥", "🧽", "🧯", "🛒", "🚬", "⚰", "🪦", "⚱", "🧿", "🗿",
+	"🪧",
+
+	// Symbols
+	"🏧", "🚮", "🚰", "♿", "🚹", "🚺", "🚻", "🚼", "🚾", "🛂",
+	"🛃", "🛄", "🛅", "⚠", "🚸", "⛔", "🚫", "🚳", "🚭", "🚯",
+	"🚱", "🚷", "📵", "🔞", "☢", "☣", "⬆", "↗", "➡", "↘",
+	"⬇", "↙", "⬅", "↖", "↕", "↔", "↩", "↪", "⤴", "⤵",
+	"🔃", "🔄", "🔙", "🔚", "🔛", "🔜", "🔝", "🛐", "⚛", "🕉",
+	"✡", "☸", "☯", "✝", "☦", "☪", "☮", "🕎", "🔯", "♈",
+	"♉", "♊", "♋", "♌", "♍", "♎", "♏", "♐", "♑", "♒",
+	"♓", "⛎", "🔀", "🔁", "🔂", "▶", "⏩", "⏭", "⏯", "◀",
+	"⏪", "⏮", "🔼", "⏫", "🔽", "⏬", "⏸", "⏹", "⏺", "⏏",
+	"🎦", "🔅", "🔆", "📶", "📳", "📴", "♀", "♂", "⚧", "✖",
+	"➕", "➖", "➗", "♾", "‼", "⁉", "❓", "❔", "❕", "❗",
+	"〰", "💱", "💲", "⚕", "♻", "⚜", "🔱", "📛", "🔰", "⭕",
+	"✅", "☑", "✔", "❌", "❎", "➰", "➿", "〽", "✳", "✴",
+	"❇", "©", "®", "™", "🔟", "🔠", "🔡", "🔢", "🔣", "🔤",
+	"🅰", "🆎", "🅱", "🆑", "🆒", "🆓", "ℹ", "🆔", "Ⓜ", "🆕",
+	"🆖", "🅾", "🆗", "🅿", "🆘", "🆙", "🆚", "🈁", "🈂", "🈷",
+	"🈶", "🈯", "🉐", "🈹", "🈚", "🈲", "🉑", "🈸", "🈴", "🈳",
+	"㊗", "㊙", "🈺", "🈵", "🔴", "🟠", "🟡", "🟢", "🔵", "🟣",
+	"🟤", "⚫", "⚪", "🟥", "🟧", "🟨", "🟩", "🟦", "🟪", "🟫",
+	"⬛", "⬜", "◼", "◻", "◾", "◽", "▪", "▫", "🔶", "🔷",
+	"🔸", "🔹", "🔺", "🔻", "💠", "🔘", "🔳", "🔲",
+
+	// Flags
+	"🏁", "🚩", "🎌", "🏴", "🏳",
+)
@@ -0,0 +1,33 @@
+package fbmsgr
+
+import "testing"
+
+func TestIsLikeEmoji(t *testing.T) {
+	testCases := map[string]bool{
+		"👍":  true,
+		"👍🏽": true,
+		"❤":  true,
+		"❤️": true,
+		"🤣":  true,
+		"🥰":  true,
+		"😅":  true,
+		"☺":  true,
+
+		"":             false,
+		"a":            false,
+		"not an emoji": false,
+		"👍👍":           false,
+		"👍 ":           false,
+		"🏻":            false,
+		"🇺":            false,
+		"🇺🇸":           false,
+		"👨‍👩‍👧":        false,
+		"#️⃣":          false,
+		"🫠":            false,
+	}
+	for emoji, expected := range testCases {
+		if actual := IsLikeEmoji(emoji); actual != expected {
+			t.Errorf("%q: expected %v but got %v", emoji, expected, actual)
+		}
+	}
+}
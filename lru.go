@@ -0,0 +1,54 @@
+package fbmsgr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// An lruSet is a bounded set of strings.
+// When the set is full, the least recently used entry is
+// evicted to make room for a new one.
+type lruSet struct {
+	lock     sync.Mutex
+	capacity int
+	order    *list.List
+	elems    map[string]*list.Element
+}
+
+func newLRUSet(capacity int) *lruSet {
+	return &lruSet{
+		capacity: capacity,
+		order:    list.New(),
+		elems:    map[string]*list.Element{},
+	}
+}
+
+// Add inserts the key into the set and reports whether or
+// not it was already present.
+func (l *lruSet) Add(key string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if elem, ok := l.elems[key]; ok {
+		l.order.MoveToFront(elem)
+		return true
+	}
+	l.elems[key] = l.order.PushFront(key)
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.elems, oldest.Value.(string))
+	}
+	return false
+}
+
+// Contains checks if the key is in the set and marks it
+// as recently used if it is.
+func (l *lruSet) Contains(key string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if elem, ok := l.elems[key]; ok {
+		l.order.MoveToFront(elem)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,559 @@
+package fbmsgr
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// DefaultMQTTEndpoint is the WebSocket URL which Messenger's
+// web client uses for MQTT.
+const DefaultMQTTEndpoint = "wss://edge-chat.messenger.com/chat"
+
+const (
+	mqttAppID        = 219994525426954
+	mqttClientID     = "mqttwsclient"
+	mqttKeepAlive    = time.Second * 60
+	mqttPingInterval = time.Second * 20
+	mqttSyncVersion  = 10
+	mqttMaxDeltas    = 1000
+	mqttBatchSize    = 500
+)
+
+// These are the MQTT control packet types used by the
+// MQTT transport.
+const (
+	mqttConnect   = 1
+	mqttConnAck   = 2
+	mqttPublish   = 3
+	mqttPubAck    = 4
+	mqttSubscribe = 8
+	mqttPingReq   = 12
+)
+
+// mqttTopics are the topics to which the MQTT transport
+// subscribes.
+var mqttTopics = []string{
+	"/t_ms",
+	"/thread_typing",
+	"/orca_typing_notifications",
+	"/orca_presence",
+	"/legacy_web",
+	"/inbox",
+	"/mercury",
+	"/messaging_events",
+	"/orca_message_notifications",
+	"/notify_disconnect",
+}
+
+// An MQTTTransport is a Transport which receives events
+// using MQTT over a WebSocket, like Messenger's current
+// web client.
+//
+// Deltas are read from a sync queue on the server.
+// When the connection drops, the transport reconnects and
+// resumes the queue where it left off.
+// If the queue cannot be resumed, the transport creates a
+// new one and reports an interruption to its sink.
+type MQTTTransport struct {
+	// Endpoint is the WebSocket URL to connect to.
+	// If it is "", DefaultMQTTEndpoint is used.
+	Endpoint string
+
+	// Origin is the Origin header for the WebSocket.
+	// If it is "", BaseURL is used.
+	Origin string
+}
+
+// mqttSyncState tracks a sync queue across connections.
+type mqttSyncState struct {
+	syncToken string
+	lastSeqID int64
+}
+
+// Run receives events until ctx is done.
+//
+// If the first connection cannot be established, Run
+// returns an error.
+// Later connection failures are retried.
+func (m *MQTTTransport) Run(ctx context.Context, s *Session, sink TransportSink) error {
+	var state mqttSyncState
+	firstAttempt := true
+	for ctx.Err() == nil {
+		if state.syncToken == "" {
+			seqID, err := s.syncSequenceID()
+			if err != nil {
+				if firstAttempt {
+					return errors.New("fetch sequence ID: " + err.Error())
+				}
+				sleepContext(ctx, pollErrTimeout)
+				continue
+			}
+			state.lastSeqID = seqID
+		}
+		connected, err := m.runConnection(ctx, s, sink, &state)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if firstAttempt && !connected {
+			// Failing to connect at all likely indicates a
+			// configuration or authentication problem.
+			return errors.New("connect: " + err.Error())
+		}
+		firstAttempt = false
+		if err == errMQTTQueueReset {
+			state = mqttSyncState{}
+			sink.Interrupted()
+			continue
+		}
+		if state.syncToken == "" {
+			sink.Interrupted()
+		}
+		sleepContext(ctx, pollErrTimeout)
+	}
+	return nil
+}
+
+var errMQTTQueueReset = errors.New("sync queue must be reset")
+
+func (m *MQTTTransport) runConnection(ctx context.Context, s *Session, sink TransportSink,
+	state *mqttSyncState) (connected bool, err error) {
+	s.randLock.Lock()
+	sessionID := s.randGen.Int63n(1 << 53)
+	s.randLock.Unlock()
+
+	ws, err := m.dial(s, sessionID)
+	if err != nil {
+		return false, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		ws.Close()
+	}()
+
+	conn := newMQTTConn(ws)
+	if err := m.handshake(conn, s, sessionID, state); err != nil {
+		return false, err
+	}
+	if state.syncToken != "" {
+		// A resumed queue is ready immediately, whereas a
+		// new queue is ready once the server responds with
+		// its sync token.
+		sink.Connected()
+	}
+
+	go func() {
+		ticker := time.NewTicker(mqttPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if conn.WritePacket(mqttPingReq<<4, nil) != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(mqttKeepAlive * 2))
+		header, body, err := conn.ReadPacket()
+		if err != nil {
+			return true, err
+		}
+		if header>>4 != mqttPublish {
+			continue
+		}
+		pub, err := parseMQTTPublish(header, body)
+		if err != nil {
+			return true, err
+		}
+		if pub.QoS > 0 {
+			ack := make([]byte, 2)
+			binary.BigEndian.PutUint16(ack, pub.PacketID)
+			if err := conn.WritePacket(mqttPubAck<<4, ack); err != nil {
+				return true, err
+			}
+		}
+		if err := handleMQTTPublish(pub, sink, state); err != nil {
+			return true, err
+		}
+	}
+}
+
+func (m *MQTTTransport) dial(s *Session, sessionID int64) (*websocket.Conn, error) {
+	endpoint := m.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultMQTTEndpoint
+	}
+	origin := m.Origin
+	if origin == "" {
+		origin = BaseURL
+	}
+
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := endpointURL.Query()
+	query.Set("region", "prn")
+	query.Set("sid", strconv.FormatInt(sessionID, 10))
+	endpointURL.RawQuery = query.Encode()
+
+	config, err := websocket.NewConfig(endpointURL.String(), origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Header = http.Header{}
+	config.Header.Set("User-Agent", SpoofedUserAgent)
+	if s.Client.Jar != nil {
+		cookieURL := *endpointURL
+		cookieURL.Scheme = strings.Replace(cookieURL.Scheme, "ws", "http", 1)
+		var cookies []string
+		for _, c := range s.Client.Jar.Cookies(&cookieURL) {
+			cookies = append(cookies, c.Name+"="+c.Value)
+		}
+		if len(cookies) > 0 {
+			config.Header.Set("Cookie", strings.Join(cookies, "; "))
+		}
+	}
+	return websocket.DialConfig(config)
+}
+
+// handshake connects to the MQTT broker, subscribes to
+// the relevant topics, and starts or resumes the sync
+// queue.
+func (m *MQTTTransport) handshake(conn *mqttConn, s *Session, sessionID int64,
+	state *mqttSyncState) error {
+	s.randLock.Lock()
+	deviceID := strconv.FormatInt(s.randGen.Int63(), 36)
+	s.randLock.Unlock()
+
+	username, err := json.Marshal(map[string]interface{}{
+		"u":          s.userID,
+		"s":          sessionID,
+		"cp":         3,
+		"ecp":        10,
+		"chat_on":    true,
+		"fg":         true,
+		"d":          deviceID,
+		"ct":         "websocket",
+		"mqtt_sid":   "",
+		"aid":        mqttAppID,
+		"st":         []string{},
+		"pm":         []string{},
+		"dc":         "",
+		"no_auto_fg": true,
+		"gas":        nil,
+	})
+	if err != nil {
+		return err
+	}
+
+	var connect []byte
+	connect = appendMQTTString(connect, "MQIsdp")
+	connect = append(connect, 3, 0x82)
+	connect = appendMQTTUint16(connect, uint16(mqttKeepAlive/time.Second))
+	connect = appendMQTTString(connect, mqttClientID)
+	connect = appendMQTTString(connect, string(username))
+	if err := conn.WritePacket(mqttConnect<<4, connect); err != nil {
+		return err
+	}
+	header, body, err := conn.ReadPacket()
+	if err != nil {
+		return err
+	}
+	if header>>4 != mqttConnAck || len(body) != 2 {
+		return errors.New("unexpected response to MQTT connect")
+	} else if body[1] != 0 {
+		return errors.New("MQTT connection refused with code " + strconv.Itoa(int(body[1])))
+	}
+
+	subscribe := appendMQTTUint16(nil, conn.NextPacketID())
+	for _, topic := range mqttTopics {
+		subscribe = appendMQTTString(subscribe, topic)
+		subscribe = append(subscribe, 0)
+	}
+	if err := conn.WritePacket(mqttSubscribe<<4|2, subscribe); err != nil {
+		return err
+	}
+
+	queueParams := map[string]interface{}{
+		"sync_api_version":           mqttSyncVersion,
+		"max_deltas_able_to_process": mqttMaxDeltas,
+		"delta_batch_size":           mqttBatchSize,
+		"encoding":                   "JSON",
+	}
+	topic := "/messenger_sync_get_diffs"
+	if state.syncToken == "" {
+		topic = "/messenger_sync_create_queue"
+		queueParams["entity_fbid"] = s.userID
+		queueParams["initial_titan_sequence_id"] = strconv.FormatInt(state.lastSeqID, 10)
+		queueParams["device_params"] = nil
+	} else {
+		queueParams["last_seq_id"] = strconv.FormatInt(state.lastSeqID, 10)
+		queueParams["sync_token"] = state.syncToken
+	}
+	payload, err := json.Marshal(queueParams)
+	if err != nil {
+		return err
+	}
+	publish := appendMQTTString(nil, topic)
+	publish = appendMQTTUint16(publish, conn.NextPacketID())
+	publish = append(publish, payload...)
+	return conn.WritePacket(mqttPublish<<4|2, publish)
+}
+
+// handleMQTTPublish converts a published message into
+// long-polling messages and passes them to the sink.
+func handleMQTTPublish(pub *mqttPublishPacket, sink TransportSink,
+	state *mqttSyncState) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(pub.Payload, &payload); err != nil {
+		// Some topics do not carry JSON payloads.
+		return nil
+	}
+	switch pub.Topic {
+	case "/t_ms":
+		return handleMQTTSync(payload, sink, state)
+	case "/thread_typing":
+		sink.Messages([]map[string]interface{}{{
+			"type":        "ttyp",
+			"from":        payload["sender_fbid"],
+			"st":          payload["state"],
+			"thread_fbid": payload["thread"],
+		}})
+	case "/orca_typing_notifications":
+		sink.Messages([]map[string]interface{}{{
+			"type": "typ",
+			"from": payload["sender_fbid"],
+			"st":   payload["state"],
+		}})
+	case "/orca_presence":
+		var presence struct {
+			List []struct {
+				User       interface{} `json:"u"`
+				LastActive float64     `json:"l"`
+			} `json:"list"`
+		}
+		if putJSONIntoObject(payload, &presence) != nil {
+			return nil
+		}
+		overlay := map[string]interface{}{}
+		for _, entry := range presence.List {
+			if fbid := canonicalFBID(entry.User); fbid != "" {
+				overlay[fbid] = map[string]interface{}{"la": entry.LastActive}
+			}
+		}
+		sink.Messages([]map[string]interface{}{{
+			"type":    "buddylist_overlay",
+			"overlay": overlay,
+		}})
+	default:
+		sink.Messages([]map[string]interface{}{{
+			"type":    pub.Topic,
+			"payload": payload,
+		}})
+	}
+	return nil
+}
+
+func handleMQTTSync(payload map[string]interface{}, sink TransportSink,
+	state *mqttSyncState) error {
+	var obj struct {
+		Deltas          []map[string]interface{} `json:"deltas"`
+		FirstDeltaSeqID int64                    `json:"firstDeltaSeqId"`
+		LastIssuedSeqID int64                    `json:"lastIssuedSeqId"`
+		SyncToken       string                   `json:"syncToken"`
+		ErrorCode       string                   `json:"errorCode"`
+	}
+	if err := putJSONIntoObject(payload, &obj); err != nil {
+		return err
+	}
+	if obj.ErrorCode != "" {
+		return errMQTTQueueReset
+	}
+	if obj.SyncToken != "" {
+		state.syncToken = obj.SyncToken
+		state.lastSeqID = obj.FirstDeltaSeqID
+		sink.Connected()
+	}
+	if obj.LastIssuedSeqID > 0 {
+		state.lastSeqID = obj.LastIssuedSeqID
+	}
+	if len(obj.Deltas) == 0 {
+		return nil
+	}
+	var msgs []map[string]interface{}
+	for _, delta := range obj.Deltas {
+		msgs = append(msgs, map[string]interface{}{
+			"type":  "delta",
+			"delta": delta,
+		})
+	}
+	sink.Messages(msgs)
+	return nil
+}
+
+// syncSequenceID fetches the sequence ID used to create
+// a new sync queue.
+func (s *Session) syncSequenceID() (int64, error) {
+	var response struct {
+		Viewer struct {
+			MessageThreads struct {
+				SyncSequenceID string `json:"sync_sequence_id"`
+			} `json:"message_threads"`
+		} `json:"viewer"`
+	}
+	params := map[string]interface{}{
+		"limit":                   1,
+		"tags":                    []string{"INBOX"},
+		"before":                  nil,
+		"includeDeliveryReceipts": false,
+		"includeSeqID":            true,
+	}
+	if err := s.graphQLDoc(threadLogDocID, params, &response); err != nil {
+		return 0, err
+	}
+	seqID := response.Viewer.MessageThreads.SyncSequenceID
+	if seqID == "" {
+		return 0, errors.New("no sequence ID in response")
+	}
+	return strconv.ParseInt(seqID, 10, 64)
+}
+
+// mqttConn reads and writes MQTT control packets.
+type mqttConn struct {
+	reader *bufio.Reader
+
+	writeLock sync.Mutex
+	writer    io.Writer
+	packetID  uint16
+}
+
+func newMQTTConn(rw io.ReadWriter) *mqttConn {
+	return &mqttConn{reader: bufio.NewReader(rw), writer: rw}
+}
+
+// ReadPacket reads the next packet, returning the first
+// byte of its fixed header and the rest of the packet.
+func (m *mqttConn) ReadPacket() (header byte, body []byte, err error) {
+	header, err = m.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var length, shift uint
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("invalid MQTT packet length")
+		}
+		b, err := m.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= uint(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(m.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// WritePacket writes a packet with the given fixed header
+// byte and body.
+func (m *mqttConn) WritePacket(header byte, body []byte) error {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		b := byte(length & 0x7f)
+		length >>= 7
+		if length > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if length == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	m.writeLock.Lock()
+	defer m.writeLock.Unlock()
+	_, err := m.writer.Write(packet)
+	return err
+}
+
+// NextPacketID generates a new, non-zero packet ID.
+func (m *mqttConn) NextPacketID() uint16 {
+	m.writeLock.Lock()
+	defer m.writeLock.Unlock()
+	m.packetID++
+	if m.packetID == 0 {
+		m.packetID = 1
+	}
+	return m.packetID
+}
+
+type mqttPublishPacket struct {
+	Topic    string
+	QoS      int
+	PacketID uint16
+	Payload  []byte
+}
+
+func parseMQTTPublish(header byte, body []byte) (*mqttPublishPacket, error) {
+	res := &mqttPublishPacket{QoS: int(header>>1) & 3}
+	if len(body) < 2 {
+		return nil, errors.New("MQTT publish packet too short")
+	}
+	topicLen := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	if len(body) < topicLen {
+		return nil, errors.New("MQTT publish packet too short")
+	}
+	res.Topic = string(body[:topicLen])
+	body = body[topicLen:]
+	if res.QoS > 0 {
+		if len(body) < 2 {
+			return nil, errors.New("MQTT publish packet too short")
+		}
+		res.PacketID = binary.BigEndian.Uint16(body)
+		body = body[2:]
+	}
+	res.Payload = body
+	return res, nil
+}
+
+func appendMQTTUint16(buf []byte, x uint16) []byte {
+	return append(buf, byte(x>>8), byte(x))
+}
+
+func appendMQTTString(buf []byte, s string) []byte {
+	buf = appendMQTTUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
@@ -0,0 +1,241 @@
+package fbmsgr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestMQTTTransport(t *testing.T) {
+	var seqLock sync.Mutex
+	var seqID int
+	session := testSession(t, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if r.URL.Path != "/api/graphqlbatch" {
+			http.NotFound(w, r)
+			return
+		}
+		seqLock.Lock()
+		seqID += 100
+		id := seqID
+		seqLock.Unlock()
+		w.Write([]byte(`{"o0":{"data":{"viewer":{"message_threads":` +
+			`{"sync_sequence_id":"` + strconv.Itoa(id) + `"}}}}}`))
+	}))
+
+	queues := make(chan map[string]interface{}, 10)
+	var connCount int32
+	broker := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		conn := newMQTTConn(ws)
+		topic, payload, err := acceptMQTTClient(conn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if topic != "/messenger_sync_create_queue" {
+			t.Errorf("unexpected queue topic: %s", topic)
+		}
+		queues <- payload
+
+		if atomic.AddInt32(&connCount, 1) == 1 {
+			publishMQTT(conn, "/t_ms", `{"syncToken":"tok","firstDeltaSeqId":101}`)
+			publishMQTT(conn, "/t_ms", `{"deltas":[{"class":"NewMessage","body":"hi"}],`+
+				`"lastIssuedSeqId":102}`)
+			publishMQTT(conn, "/t_ms", `{"errorCode":"ERROR_QUEUE_OVERFLOW"}`)
+		}
+		for {
+			if _, _, err := conn.ReadPacket(); err != nil {
+				return
+			}
+		}
+	}))
+	defer broker.Close()
+
+	sink := newTestSink()
+	transport := &MQTTTransport{
+		Endpoint: "ws" + strings.TrimPrefix(broker.URL, "http") + "/chat",
+		Origin:   "http://localhost",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- transport.Run(ctx, session, sink)
+	}()
+
+	firstQueue := waitForQueue(t, queues)
+	if firstQueue["initial_titan_sequence_id"] != "100" {
+		t.Errorf("unexpected first queue: %v", firstQueue)
+	}
+	if firstQueue["entity_fbid"] != "1234" {
+		t.Errorf("unexpected entity: %v", firstQueue["entity_fbid"])
+	}
+
+	select {
+	case <-sink.connects:
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for connection")
+	}
+
+	var msgs []map[string]interface{}
+	select {
+	case msgs = <-sink.messages:
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for messages")
+	}
+	if len(msgs) != 1 || msgs[0]["type"] != "delta" {
+		t.Fatalf("unexpected messages: %v", msgs)
+	}
+	delta := msgs[0]["delta"].(map[string]interface{})
+	if delta["class"] != "NewMessage" || delta["body"] != "hi" {
+		t.Errorf("unexpected delta: %v", delta)
+	}
+
+	// The errorCode should cause a new queue to be created
+	// from a fresh sequence ID.
+	select {
+	case <-sink.interrupts:
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for interruption")
+	}
+	secondQueue := waitForQueue(t, queues)
+	if secondQueue["initial_titan_sequence_id"] != "200" {
+		t.Errorf("unexpected second queue: %v", secondQueue)
+	}
+
+	cancel()
+	if err := waitForError(t, runErr); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMQTTTransportConnectFailure(t *testing.T) {
+	session := testSession(t, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Write([]byte(`{"o0":{"data":{"viewer":{"message_threads":` +
+			`{"sync_sequence_id":"1"}}}}}`))
+	}))
+	refusing := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		conn := newMQTTConn(ws)
+		if _, _, err := conn.ReadPacket(); err != nil {
+			return
+		}
+		conn.WritePacket(mqttConnAck<<4, []byte{0, 5})
+	}))
+	defer refusing.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, endpoint := range []string{refusing.URL, closed.URL} {
+		transport := &MQTTTransport{
+			Endpoint: "ws" + strings.TrimPrefix(endpoint, "http") + "/chat",
+			Origin:   "http://localhost",
+		}
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- transport.Run(context.Background(), session, newTestSink())
+		}()
+		if err := waitForError(t, runErr); err == nil {
+			t.Errorf("expected error for %s", endpoint)
+		}
+	}
+}
+
+// acceptMQTTClient performs the broker's side of the
+// handshake, returning the sync queue request.
+func acceptMQTTClient(conn *mqttConn) (string, map[string]interface{}, error) {
+	header, body, err := conn.ReadPacket()
+	if err != nil {
+		return "", nil, err
+	}
+	if header>>4 != mqttConnect || !strings.Contains(string(body), "MQIsdp") {
+		return "", nil, errors.New("expected CONNECT")
+	}
+	if err := conn.WritePacket(mqttConnAck<<4, []byte{0, 0}); err != nil {
+		return "", nil, err
+	}
+	header, _, err = conn.ReadPacket()
+	if err != nil {
+		return "", nil, err
+	}
+	if header>>4 != mqttSubscribe {
+		return "", nil, errors.New("expected SUBSCRIBE")
+	}
+	header, body, err = conn.ReadPacket()
+	if err != nil {
+		return "", nil, err
+	}
+	if header>>4 != mqttPublish {
+		return "", nil, errors.New("expected PUBLISH")
+	}
+	pub, err := parseMQTTPublish(header, body)
+	if err != nil {
+		return "", nil, err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(pub.Payload, &payload); err != nil {
+		return "", nil, err
+	}
+	return pub.Topic, payload, nil
+}
+
+func publishMQTT(conn *mqttConn, topic, payload string) {
+	conn.WritePacket(mqttPublish<<4, append(appendMQTTString(nil, topic), payload...))
+}
+
+type testSink struct {
+	messages   chan []map[string]interface{}
+	connects   chan struct{}
+	interrupts chan struct{}
+}
+
+func newTestSink() *testSink {
+	return &testSink{
+		messages:   make(chan []map[string]interface{}, 100),
+		connects:   make(chan struct{}, 100),
+		interrupts: make(chan struct{}, 100),
+	}
+}
+
+func (t *testSink) Connected() {
+	t.connects <- struct{}{}
+}
+
+func (t *testSink) Messages(msgs []map[string]interface{}) {
+	t.messages <- msgs
+}
+
+func (t *testSink) Interrupted() {
+	t.interrupts <- struct{}{}
+}
+
+func waitForQueue(t *testing.T, ch <-chan map[string]interface{}) map[string]interface{} {
+	select {
+	case x := <-ch:
+		return x
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for sync queue")
+		return nil
+	}
+}
+
+func waitForError(t *testing.T, ch <-chan error) error {
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for result")
+		return nil
+	}
+}
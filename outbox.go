@@ -0,0 +1,245 @@
+package fbmsgr
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+const (
+	defaultOutboxMaxAttempts = 5
+	defaultOutboxMinBackoff  = time.Second
+	defaultOutboxMaxBackoff  = time.Minute * 5
+)
+
+// OutboxStatus is the final delivery status of a message
+// in an Outbox.
+type OutboxStatus int
+
+const (
+	OutboxSent OutboxStatus = iota
+	OutboxFailed
+)
+
+// An OutboxResult reports the outcome of sending a
+// message from an Outbox.
+type OutboxResult struct {
+	// ID is the ID returned by Outbox.Enqueue.
+	// It is also the message's offline threading ID.
+	ID string
+
+	Thread ThreadID
+	Status OutboxStatus
+
+	// MessageID is the ID of the sent message.
+	// It is "" if the message could not be sent.
+	MessageID string
+
+	// Attempts is the number of times the message was
+	// sent, including the final attempt.
+	Attempts int
+
+	// Err is the error from the last attempt, if the
+	// message could not be sent.
+	Err error
+}
+
+// OutboxOptions stores optional settings for an Outbox.
+type OutboxOptions struct {
+	// MaxAttempts is the number of times a message may be
+	// sent before it is reported as failed.
+	// If 0, a default value is used.
+	MaxAttempts int
+
+	// MinBackoff and MaxBackoff bound the delay between
+	// attempts, which doubles after every failure.
+	// If 0, default values are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnResult, if non-nil, is called from the Outbox's
+	// Goroutine whenever a message is sent or fails.
+	OnResult func(res *OutboxResult)
+}
+
+// An Outbox is a durable queue of outgoing messages.
+//
+// Pending messages are saved to a file, so they will be
+// sent even if the process exits before they are sent.
+// Failed sends are retried with exponential backoff.
+// Every attempt reuses the message's offline threading ID,
+// so a retry will not duplicate a message that was already
+// delivered.
+//
+// Messages sent to the same thread are delivered in the
+// order they were enqueued.
+type Outbox struct {
+	persistentQueue
+
+	options OutboxOptions
+	state   outboxState
+}
+
+type outboxState struct {
+	Entries []*outboxEntry `json:"entries"`
+}
+
+type outboxEntry struct {
+	ID          string    `json:"id"`
+	Thread      ThreadID  `json:"thread"`
+	Message     *Message  `json:"message"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// NewOutbox creates an Outbox which stores its pending
+// messages in the file at path.
+//
+// If the file exists, the messages it contains are
+// loaded and sent.
+// If opts is nil, the default options are used.
+//
+// You must close the Outbox when you are done with it.
+func NewOutbox(s *Session, path string, opts *OutboxOptions) (outbox *Outbox, err error) {
+	defer essentials.AddCtxTo("fbmsgr: new outbox", &err)
+	if opts == nil {
+		opts = &OutboxOptions{}
+	}
+	res := &Outbox{options: *opts}
+	if res.options.MaxAttempts == 0 {
+		res.options.MaxAttempts = defaultOutboxMaxAttempts
+	}
+
+	if err := res.open(s, path, &res.state, res.options.MinBackoff,
+		res.options.MaxBackoff); err != nil {
+		return nil, err
+	}
+	res.start(res.nextAttempt)
+	return res, nil
+}
+
+// Enqueue adds a message to the outbox.
+//
+// Messages which could never be sent, such as empty
+// messages, are rejected immediately.
+// Otherwise, the message is saved to disk before Enqueue
+// returns.
+// The resulting ID is used as the message's offline
+// threading ID, and identifies the message in an
+// OutboxResult.
+func (o *Outbox) Enqueue(thread ThreadID, msg *Message) (id string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: enqueue message", &err)
+	if err := checkMessage(msg); err != nil {
+		return "", err
+	}
+
+	msgCopy := *msg
+	if msgCopy.OfflineThreadingID == "" {
+		msgCopy.OfflineThreadingID = o.session.randomMessageID()
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.closed() {
+		return "", errors.New("outbox is closed")
+	}
+	o.state.Entries = append(o.state.Entries, &outboxEntry{
+		ID:      msgCopy.OfflineThreadingID,
+		Thread:  thread,
+		Message: &msgCopy,
+	})
+	if err := o.save(); err != nil {
+		o.state.Entries = o.state.Entries[:len(o.state.Entries)-1]
+		return "", err
+	}
+
+	o.notify()
+	return msgCopy.OfflineThreadingID, nil
+}
+
+// Len returns the number of messages waiting to be sent.
+func (o *Outbox) Len() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return len(o.state.Entries)
+}
+
+// Close stops sending messages.
+//
+// Pending messages remain on disk, and will be sent by
+// the next Outbox created with the same path.
+func (o *Outbox) Close() error {
+	o.stop()
+	return nil
+}
+
+func (o *Outbox) nextAttempt() (time.Time, func()) {
+	entry := o.nextEntry()
+	if entry == nil {
+		return time.Time{}, nil
+	}
+	return entry.NextAttempt, func() {
+		o.attempt(entry)
+	}
+}
+
+// nextEntry finds the entry which should be sent next.
+//
+// Only the oldest entry in each thread may be sent, so
+// that messages are delivered in order.
+func (o *Outbox) nextEntry() *outboxEntry {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	seenThreads := map[ThreadID]bool{}
+	var res *outboxEntry
+	for _, entry := range o.state.Entries {
+		if seenThreads[entry.Thread] {
+			continue
+		}
+		seenThreads[entry.Thread] = true
+		if res == nil || entry.NextAttempt.Before(res.NextAttempt) {
+			res = entry
+		}
+	}
+	return res
+}
+
+func (o *Outbox) attempt(entry *outboxEntry) {
+	sendRes := o.send(entry.Thread, entry.Message, entry.Attempts)
+	if sendRes.Stopped {
+		return
+	}
+
+	o.lock.Lock()
+	entry.Attempts++
+	var result *OutboxResult
+	if sendRes.Err == nil {
+		result = &OutboxResult{Status: OutboxSent, MessageID: sendRes.MessageID}
+	} else if sendRes.Retry.IsZero() || entry.Attempts >= o.options.MaxAttempts {
+		result = &OutboxResult{Status: OutboxFailed, Err: sendRes.Err}
+	} else {
+		entry.NextAttempt = sendRes.Retry
+	}
+	if result != nil {
+		result.ID = entry.ID
+		result.Thread = entry.Thread
+		result.Attempts = entry.Attempts
+		o.removeEntry(entry)
+	}
+	o.saveAfterSend()
+	o.lock.Unlock()
+
+	if result != nil && o.options.OnResult != nil {
+		o.options.OnResult(result)
+	}
+}
+
+func (o *Outbox) removeEntry(entry *outboxEntry) {
+	for i, x := range o.state.Entries {
+		if x == entry {
+			o.state.Entries = append(o.state.Entries[:i], o.state.Entries[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package fbmsgr
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutboxRejectsInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fbmsgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outbox.json")
+
+	session := testSession(t, http.NotFoundHandler())
+	outbox, err := NewOutbox(session, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+
+	for _, msg := range []*Message{
+		{},
+		likeMessage("not an emoji", LargeEmoji),
+		likeMessage("👍", "huge"),
+		{Body: "hi", EphemeralTTL: 7},
+	} {
+		if _, err := outbox.Enqueue(UserThreadID("1"), msg); err == nil {
+			t.Errorf("expected error for %+v", msg)
+		}
+	}
+	if outbox.Len() != 0 {
+		t.Errorf("unexpected length: %d", outbox.Len())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("outbox file should not exist")
+	}
+}
+
+func TestOutboxInvalidLoadedEntry(t *testing.T) {
+	for _, message := range []string{
+		`"message":{"Body":""},`,
+		`"message":null,`,
+		``,
+	} {
+		testOutboxInvalidLoadedEntry(t, message)
+	}
+}
+
+func testOutboxInvalidLoadedEntry(t *testing.T, message string) {
+	dir, err := ioutil.TempDir("", "fbmsgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outbox.json")
+	data := `{"entries":[{"id":"1","thread":{"FBID":"2","Group":false},` + message +
+		`"attempts":0,"next_attempt":"2000-01-01T00:00:00Z"}]}`
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan *OutboxResult, 10)
+	session := testSession(t, http.NotFoundHandler())
+	outbox, err := NewOutbox(session, path, &OutboxOptions{
+		OnResult: func(res *OutboxResult) {
+			results <- res
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+
+	select {
+	case res := <-results:
+		if res.Status != OutboxFailed || res.Attempts != 1 || res.Err == nil {
+			t.Errorf("%s: unexpected result: %+v", message, res)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out")
+	}
+	if outbox.Len() != 0 {
+		t.Errorf("%s: unexpected length: %d", message, outbox.Len())
+	}
+}
@@ -0,0 +1,221 @@
+package fbmsgr
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A persistentQueue is the machinery shared by Outbox and
+// Scheduler: a state which is saved to a JSON file, and a
+// Goroutine which sends messages from the state at
+// scheduled times, retrying them with exponential backoff.
+type persistentQueue struct {
+	session    *Session
+	path       string
+	state      interface{}
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	lock    sync.Mutex
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	doneSig chan struct{}
+}
+
+// queueSendResult is the outcome of sending a message from
+// a persistentQueue.
+type queueSendResult struct {
+	MessageID string
+	Err       error
+
+	// Retry is the time at which the message should be sent
+	// again, or the zero time if it should not be retried,
+	// either because it was sent or because it could never
+	// be sent.
+	Retry time.Time
+
+	// Stopped is true if the queue was stopped before the
+	// message could be sent, in which case the attempt
+	// should not be counted.
+	Stopped bool
+}
+
+// open loads the state from the file at path, if the file
+// exists.
+// The state must be a pointer to a JSON-encodable value.
+//
+// The backoff bounds are used to schedule retries.
+// If they are 0, default values are used.
+func (p *persistentQueue) open(s *Session, path string, state interface{},
+	minBackoff, maxBackoff time.Duration) error {
+	p.session = s
+	p.path = path
+	p.state = state
+	p.minBackoff = minBackoff
+	if p.minBackoff == 0 {
+		p.minBackoff = defaultOutboxMinBackoff
+	}
+	p.maxBackoff = maxBackoff
+	if p.maxBackoff == 0 {
+		p.maxBackoff = defaultOutboxMaxBackoff
+	}
+	p.wake = make(chan struct{}, 1)
+	p.doneSig = make(chan struct{})
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	} else if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start launches the queue's Goroutine.
+//
+// The next function is called without p.lock held, and
+// returns the next action and the time at which it should
+// be run.
+// If there is nothing to do, next returns a nil action.
+// Whenever the state changes, notify should be called so
+// that next is called again.
+func (p *persistentQueue) start(next func() (time.Time, func())) {
+	go p.run(next)
+}
+
+func (p *persistentQueue) run(next func() (time.Time, func())) {
+	defer close(p.doneSig)
+	for {
+		t, action := next()
+		var timer *time.Timer
+		var timerChan <-chan time.Time
+		if action != nil {
+			timer = time.NewTimer(time.Until(t))
+			timerChan = timer.C
+		}
+		select {
+		case <-p.ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-p.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+			continue
+		case <-timerChan:
+		}
+		action()
+	}
+}
+
+// stop stops the queue's Goroutine and waits for it to
+// exit.
+func (p *persistentQueue) stop() {
+	p.cancel()
+	<-p.doneSig
+}
+
+// closed checks if stop has been called.
+func (p *persistentQueue) closed() bool {
+	return p.ctx.Err() != nil
+}
+
+// notify wakes up the queue's Goroutine so that it
+// notices changes to the state.
+func (p *persistentQueue) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// send sends a message from the queue, which has already
+// failed the given number of times.
+// The caller must not hold p.lock.
+func (p *persistentQueue) send(thread ThreadID, msg *Message,
+	failures int) *queueSendResult {
+	// A message loaded from disk may be missing or invalid,
+	// in which case retrying it is pointless.
+	if err := checkMessage(msg); err != nil {
+		return &queueSendResult{Err: err}
+	}
+	msgID, err := p.session.sendContext(p.ctx, thread, msg)
+	res := &queueSendResult{MessageID: msgID, Err: err}
+	if err != nil && p.closed() {
+		res.Stopped = true
+	} else if err != nil {
+		res.Retry = time.Now().Add(p.backoff(failures + 1))
+	}
+	return res
+}
+
+// backoff computes the delay before the next attempt
+// after a given number of failed attempts.
+// The delay starts at p.minBackoff and doubles after every
+// failure, up to p.maxBackoff.
+func (p *persistentQueue) backoff(failures int) time.Duration {
+	res := p.minBackoff
+	for i := 1; i < failures && res < p.maxBackoff; i++ {
+		res *= 2
+	}
+	if res > p.maxBackoff {
+		res = p.maxBackoff
+	}
+	return res
+}
+
+// save writes the state to disk.
+// The caller must hold p.lock.
+func (p *persistentQueue) save() error {
+	data, err := json.Marshal(p.state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path, data)
+}
+
+// saveAfterSend writes the state to disk after a message
+// was sent, or failed to send.
+// The caller must hold p.lock.
+//
+// If saving fails, the message may be sent again after a
+// restart, in which case its offline threading ID will
+// prevent a duplicate.
+func (p *persistentQueue) saveAfterSend() {
+	p.save()
+}
+
+// writeFileAtomic replaces the contents of a file such
+// that readers never see a partially-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package fbmsgr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxIdleThreadBuckets is the number of per-thread token
+// buckets after which full buckets are discarded.
+const maxIdleThreadBuckets = 1000
+
+// ErrRateLimited is returned when a message is not sent
+// because it would exceed the Session's rate limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ErrSessionClosed is returned when a Session is closed
+// while a message is waiting for the rate limit.
+var ErrSessionClosed = errors.New("session closed")
+
+// RateLimitOptions configures the rate at which a Session
+// may send messages.
+//
+// Limits are enforced with token buckets: a bucket holds
+// up to Burst tokens, every message uses one token, and
+// tokens are refilled at Rate tokens per second.
+type RateLimitOptions struct {
+	// GlobalRate and GlobalBurst limit the messages sent
+	// to all threads combined.
+	// If GlobalRate is 0, there is no global limit.
+	GlobalRate  float64
+	GlobalBurst int
+
+	// ThreadRate and ThreadBurst limit the messages sent
+	// to any single thread.
+	// If ThreadRate is 0, there is no per-thread limit.
+	ThreadRate  float64
+	ThreadBurst int
+
+	// FailFast causes sends to fail with ErrRateLimited
+	// rather than waiting when the limit is exceeded.
+	FailFast bool
+}
+
+// SetRateLimit limits the rate at which messages can be
+// sent with the Session.
+//
+// Every method which sends a message, including SendText,
+// SendAttachment, and SendLike, is subject to the limit.
+// A message which is waiting for the limit is not sent if
+// the Session is closed in the meantime.
+// If opts is nil, the rate limit is removed.
+func (s *Session) SetRateLimit(opts *RateLimitOptions) {
+	s.rateLimitLock.Lock()
+	defer s.rateLimitLock.Unlock()
+	if opts == nil {
+		s.rateLimiter = nil
+	} else {
+		s.rateLimiter = newRateLimiter(opts)
+	}
+}
+
+// waitRateLimit blocks until a message can be sent to the
+// thread without exceeding the rate limit.
+// It fails early if ctx is done or the Session is closed.
+func (s *Session) waitRateLimit(ctx context.Context, thread string) error {
+	s.rateLimitLock.Lock()
+	limiter := s.rateLimiter
+	s.rateLimitLock.Unlock()
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx, s.closed, thread)
+}
+
+type rateLimiter struct {
+	lock    sync.Mutex
+	options RateLimitOptions
+	global  *tokenBucket
+	threads map[string]*tokenBucket
+
+	// now and after are time.Now and time.After, except in
+	// tests.
+	now   func() time.Time
+	after func(d time.Duration) <-chan time.Time
+}
+
+func newRateLimiter(opts *RateLimitOptions) *rateLimiter {
+	return newRateLimiterClock(opts, time.Now, time.After)
+}
+
+func newRateLimiterClock(opts *RateLimitOptions, now func() time.Time,
+	after func(d time.Duration) <-chan time.Time) *rateLimiter {
+	res := &rateLimiter{
+		options: *opts,
+		threads: map[string]*tokenBucket{},
+		now:     now,
+		after:   after,
+	}
+	if opts.GlobalRate > 0 {
+		res.global = newTokenBucket(opts.GlobalRate, opts.GlobalBurst, now())
+	}
+	return res
+}
+
+// Wait takes a token from the global bucket and from the
+// thread's bucket, waiting until both are available.
+//
+// If ctx is done or closed is closed before the tokens
+// are available, the tokens are returned and an error is
+// returned.
+func (r *rateLimiter) Wait(ctx context.Context, closed <-chan struct{},
+	thread string) error {
+	r.lock.Lock()
+	now := r.now()
+	var buckets []*tokenBucket
+	if r.global != nil {
+		buckets = append(buckets, r.global)
+	}
+	if r.options.ThreadRate > 0 {
+		bucket, ok := r.threads[thread]
+		if !ok {
+			r.pruneThreads(now)
+			bucket = newTokenBucket(r.options.ThreadRate, r.options.ThreadBurst, now)
+			r.threads[thread] = bucket
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	var delay time.Duration
+	for _, bucket := range buckets {
+		if d := bucket.Delay(now); d > delay {
+			delay = d
+		}
+	}
+	if delay > 0 && r.options.FailFast {
+		r.lock.Unlock()
+		return ErrRateLimited
+	}
+	for _, bucket := range buckets {
+		bucket.Take()
+	}
+	r.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	var err error
+	select {
+	case <-r.after(delay):
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-closed:
+		err = ErrSessionClosed
+	}
+	r.lock.Lock()
+	for _, bucket := range buckets {
+		bucket.Give()
+	}
+	r.lock.Unlock()
+	return err
+}
+
+func (r *rateLimiter) pruneThreads(now time.Time) {
+	if len(r.threads) < maxIdleThreadBuckets {
+		return
+	}
+	for thread, bucket := range r.threads {
+		if bucket.Full(now) {
+			delete(r.threads, thread)
+		}
+	}
+}
+
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:     rate,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		lastFill: now,
+	}
+}
+
+// Delay refills the bucket and returns the time until a
+// token will be available.
+func (t *tokenBucket) Delay(now time.Time) time.Duration {
+	t.fill(now)
+	if t.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - t.tokens) / t.rate * float64(time.Second))
+}
+
+// Take removes a token from the bucket.
+//
+// If no token is available, the bucket goes into debt,
+// reserving the next token for the caller.
+func (t *tokenBucket) Take() {
+	t.tokens--
+}
+
+// Give returns a token which was taken but not used.
+func (t *tokenBucket) Give() {
+	t.tokens++
+	if t.tokens > t.capacity {
+		t.tokens = t.capacity
+	}
+}
+
+// Full checks if the bucket would be full at the given
+// time.
+func (t *tokenBucket) Full(now time.Time) bool {
+	t.fill(now)
+	return t.tokens >= t.capacity
+}
+
+func (t *tokenBucket) fill(now time.Time) {
+	if elapsed := now.Sub(t.lastFill); elapsed > 0 {
+		t.tokens += elapsed.Seconds() * t.rate
+		if t.tokens > t.capacity {
+			t.tokens = t.capacity
+		}
+		t.lastFill = now
+	}
+}
@@ -0,0 +1,213 @@
+package fbmsgr
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	bucket := newTokenBucket(2, 3, start)
+
+	for i := 0; i < 3; i++ {
+		if d := bucket.Delay(start); d != 0 {
+			t.Fatalf("token %d: unexpected delay %v", i, d)
+		}
+		bucket.Take()
+	}
+	if d := bucket.Delay(start); d != time.Second/2 {
+		t.Errorf("expected delay %v but got %v", time.Second/2, d)
+	}
+	if d := bucket.Delay(start.Add(time.Second / 4)); d != time.Second/4 {
+		t.Errorf("expected delay %v but got %v", time.Second/4, d)
+	}
+	if bucket.Full(start.Add(time.Second)) {
+		t.Error("bucket should not be full yet")
+	}
+
+	// Tokens stop accumulating at the burst size.
+	later := start.Add(time.Hour)
+	if !bucket.Full(later) {
+		t.Error("bucket should be full")
+	}
+	for i := 0; i < 3; i++ {
+		bucket.Take()
+	}
+	if d := bucket.Delay(later); d != time.Second/2 {
+		t.Errorf("expected delay %v but got %v", time.Second/2, d)
+	}
+}
+
+func TestRateLimiterDebt(t *testing.T) {
+	clock := newTestClock()
+	limiter := newRateLimiterClock(&RateLimitOptions{
+		GlobalRate:  1,
+		GlobalBurst: 1,
+		ThreadRate:  0.5,
+		ThreadBurst: 2,
+	}, clock.Now, clock.After)
+
+	// Every waiter reserves a token, so each one waits
+	// longer than the one before it.
+	expected := []time.Duration{0, time.Second, time.Second * 2}
+	for i, delay := range expected {
+		if err := limiter.Wait(context.Background(), nil, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if d := clock.LastDelay(); d != delay {
+			t.Errorf("wait %d: expected delay %v but got %v", i, delay, d)
+		}
+	}
+
+	// Thread a is still in debt after the global bucket has
+	// refilled, so it is limited by its own rate.
+	clock.Advance(time.Second * 3)
+	if err := limiter.Wait(context.Background(), nil, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.LastDelay(); d != time.Second {
+		t.Errorf("expected delay %v but got %v", time.Second, d)
+	}
+
+	// Other threads only wait for the global bucket.
+	clock.Advance(time.Second * 3)
+	if err := limiter.Wait(context.Background(), nil, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.LastDelay(); d != 0 {
+		t.Errorf("expected no delay but got %v", d)
+	}
+}
+
+func TestRateLimiterFailFast(t *testing.T) {
+	clock := newTestClock()
+	limiter := newRateLimiterClock(&RateLimitOptions{
+		ThreadRate:  1,
+		ThreadBurst: 1,
+		FailFast:    true,
+	}, clock.Now, clock.After)
+
+	if err := limiter.Wait(context.Background(), nil, "a"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(context.Background(), nil, "a"); err != ErrRateLimited {
+			t.Errorf("attempt %d: expected ErrRateLimited but got %v", i, err)
+		}
+	}
+	if err := limiter.Wait(context.Background(), nil, "b"); err != nil {
+		t.Error(err)
+	}
+
+	// Failed attempts should not have used any tokens.
+	clock.Advance(time.Second)
+	if err := limiter.Wait(context.Background(), nil, "a"); err != nil {
+		t.Error(err)
+	}
+	if d := clock.LastDelay(); d != 0 {
+		t.Errorf("expected no delay but got %v", d)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	clock := newTestClock()
+	clock.Block = true
+	limiter := newRateLimiterClock(&RateLimitOptions{
+		GlobalRate:  1,
+		GlobalBurst: 1,
+	}, clock.Now, clock.After)
+
+	if err := limiter.Wait(context.Background(), nil, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx, nil, "a"); err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+	closed := make(chan struct{})
+	close(closed)
+	if err := limiter.Wait(context.Background(), closed, "a"); err != ErrSessionClosed {
+		t.Errorf("expected ErrSessionClosed but got %v", err)
+	}
+
+	// The cancelled waiters should have returned their
+	// tokens.
+	clock.Block = false
+	if err := limiter.Wait(context.Background(), nil, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.LastDelay(); d != time.Second {
+		t.Errorf("expected delay %v but got %v", time.Second, d)
+	}
+}
+
+func TestSessionCloseRateLimit(t *testing.T) {
+	session := testSession(t, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Write([]byte(`for (;;);{"payload":{"actions":[{"message_id":"mid.1"}]}}`))
+	}))
+	session.SetRateLimit(&RateLimitOptions{GlobalRate: 0.001, GlobalBurst: 1})
+
+	if _, err := session.SendText("5", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := session.SendText("5", "hi")
+		errChan <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+	session.Close()
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Error("expected error")
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("send did not stop after Close")
+	}
+}
+
+// testClock is a fake clock for a rateLimiter.
+//
+// Its After channels fire immediately, unless Block is
+// set, in which case they never fire.
+type testClock struct {
+	Block bool
+
+	now       time.Time
+	lastDelay time.Duration
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Unix(1500000000, 0)}
+}
+
+func (t *testClock) Now() time.Time {
+	return t.now
+}
+
+func (t *testClock) After(d time.Duration) <-chan time.Time {
+	t.lastDelay = d
+	ch := make(chan time.Time, 1)
+	if !t.Block {
+		ch <- t.now.Add(d)
+	}
+	return ch
+}
+
+func (t *testClock) Advance(d time.Duration) {
+	t.now = t.now.Add(d)
+}
+
+// LastDelay returns the delay passed to the last After
+// call, and then resets it.
+func (t *testClock) LastDelay() time.Duration {
+	res := t.lastDelay
+	t.lastDelay = 0
+	return res
+}
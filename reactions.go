@@ -0,0 +1,34 @@
+package fbmsgr
+
+import "github.com/unixpickle/essentials"
+
+const reactionDocID = "1491398900900362"
+
+// React adds an emoji reaction to a message, replacing
+// any reaction that the user previously added to it.
+//
+// The emoji should be a single emoji character, such as
+// "👍" or "❤".
+func (s *Session) React(messageID, emoji string) (err error) {
+	defer essentials.AddCtxTo("fbmsgr: react", &err)
+	return s.setReaction(messageID, "ADD_REACTION", emoji)
+}
+
+// Unreact removes the user's reaction from a message.
+func (s *Session) Unreact(messageID string) (err error) {
+	defer essentials.AddCtxTo("fbmsgr: unreact", &err)
+	return s.setReaction(messageID, "REMOVE_REACTION", "")
+}
+
+func (s *Session) setReaction(messageID, action, emoji string) error {
+	data := map[string]interface{}{
+		"client_mutation_id": "1",
+		"actor_id":           s.userID,
+		"action":             action,
+		"message_id":         messageID,
+	}
+	if emoji != "" {
+		data["reaction"] = emoji
+	}
+	return s.graphQLMutation(reactionDocID, map[string]interface{}{"data": data}, nil)
+}
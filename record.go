@@ -0,0 +1,227 @@
+package fbmsgr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// recordableEvents lists the Event types which can be
+// recorded by an EventRecorder.
+var recordableEvents = typeRegistry(
+	MessageEvent{},
+	BuddyEvent{},
+	TypingEvent{},
+	DeleteMessageEvent{},
+	BackfillFailedEvent{},
+	UnknownEvent{},
+)
+
+// recordableAttachments lists the Attachment types which
+// can be recorded as part of a MessageEvent.
+var recordableAttachments = typeRegistry(
+	&UnknownAttachment{},
+	&AudioAttachment{},
+	&ImageAttachment{},
+	&StickerAttachment{},
+	&FileAttachment{},
+	&VideoAttachment{},
+	&LocationAttachment{},
+	&ShareAttachment{},
+)
+
+// recordedEvent is the JSON object stored on each line of
+// an event recording.
+type recordedEvent struct {
+	Time        time.Time            `json:"time"`
+	Type        string               `json:"type"`
+	Event       json.RawMessage      `json:"event"`
+	Attachments []recordedAttachment `json:"attachments,omitempty"`
+}
+
+type recordedAttachment struct {
+	Type       string          `json:"type"`
+	Attachment json.RawMessage `json:"attachment"`
+}
+
+// An EventRecorder writes Events to a JSON Lines stream
+// which can later be replayed with ReplayEvents.
+//
+// It is safe to use an EventRecorder from multiple
+// Goroutines concurrently.
+type EventRecorder struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewEventRecorder creates an EventRecorder that writes
+// to w.
+func NewEventRecorder(w io.Writer) *EventRecorder {
+	return &EventRecorder{encoder: json.NewEncoder(w)}
+}
+
+// Record writes an event to the recording, marking it
+// with the current time.
+func (r *EventRecorder) Record(evt Event) (err error) {
+	defer essentials.AddCtxTo("fbmsgr: record event", &err)
+
+	rec := recordedEvent{Time: time.Now()}
+	rec.Type, err = registeredTypeName(recordableEvents, evt)
+	if err != nil {
+		return err
+	}
+	if msg, ok := evt.(MessageEvent); ok {
+		for _, a := range msg.Attachments {
+			attachType, err := registeredTypeName(recordableAttachments, a)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(a)
+			if err != nil {
+				return err
+			}
+			rec.Attachments = append(rec.Attachments, recordedAttachment{
+				Type:       attachType,
+				Attachment: data,
+			})
+		}
+		msg.Attachments = nil
+		evt = msg
+	}
+	rec.Event, err = json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.encoder.Encode(&rec)
+}
+
+// ReplayEvents creates an EventStream which emits the
+// events from a recording produced by an EventRecorder.
+//
+// The speed argument controls the timing of the events.
+// A speed of 1 replays events with their original timing,
+// a speed of 2 replays them twice as fast, etc.
+// A speed of 0 emits the events without any delay.
+//
+// If the recording is malformed, the stream fails with an
+// error.
+// The stream does not close r.
+func ReplayEvents(r io.Reader, speed float64) *EventStream {
+	res := &EventStream{
+		evtChan: make(chan Event, 1),
+	}
+	res.ctx, res.cancel = context.WithCancel(context.Background())
+	go res.replay(json.NewDecoder(r), speed)
+	return res
+}
+
+func (e *EventStream) replay(decoder *json.Decoder, speed float64) {
+	defer close(e.evtChan)
+
+	var lastTime time.Time
+	for {
+		var rec recordedEvent
+		if err := decoder.Decode(&rec); err == io.EOF {
+			return
+		} else if err != nil {
+			e.pollFailed(errors.New("replay: " + err.Error()))
+			return
+		}
+		evt, err := decodeRecordedEvent(&rec)
+		if err != nil {
+			e.pollFailed(errors.New("replay: " + err.Error()))
+			return
+		}
+		if speed > 0 && !lastTime.IsZero() && rec.Time.After(lastTime) {
+			delay := time.Duration(float64(rec.Time.Sub(lastTime)) / speed)
+			select {
+			case <-time.After(delay):
+			case <-e.ctx.Done():
+				return
+			}
+		}
+		lastTime = rec.Time
+		if e.checkClosed() {
+			return
+		}
+		e.emitEvent(evt)
+	}
+}
+
+func decodeRecordedEvent(rec *recordedEvent) (Event, error) {
+	evt, err := decodeRegisteredType(recordableEvents, rec.Type, rec.Event)
+	if err != nil {
+		return nil, err
+	}
+	if msg, ok := evt.(MessageEvent); ok {
+		for _, a := range rec.Attachments {
+			attachment, err := decodeRegisteredType(recordableAttachments, a.Type,
+				a.Attachment)
+			if err != nil {
+				return nil, err
+			}
+			msg.Attachments = append(msg.Attachments, attachment.(Attachment))
+		}
+		evt = msg
+	}
+	return evt, nil
+}
+
+// typeRegistry creates a mapping from type names to the
+// types of the given values.
+func typeRegistry(values ...interface{}) map[string]reflect.Type {
+	res := map[string]reflect.Type{}
+	for _, v := range values {
+		t := reflect.TypeOf(v)
+		if t.Kind() == reflect.Ptr {
+			res[t.Elem().Name()] = t
+		} else {
+			res[t.Name()] = t
+		}
+	}
+	return res
+}
+
+func registeredTypeName(registry map[string]reflect.Type, v interface{}) (string, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return "", errors.New("unsupported type: nil")
+	}
+	name := t.Name()
+	if t.Kind() == reflect.Ptr {
+		name = t.Elem().Name()
+	}
+	if registry[name] != t {
+		return "", errors.New("unsupported type: " + t.String())
+	}
+	return name, nil
+}
+
+func decodeRegisteredType(registry map[string]reflect.Type, name string,
+	data json.RawMessage) (interface{}, error) {
+	t, ok := registry[name]
+	if !ok {
+		return nil, errors.New("unsupported type: " + name)
+	}
+	if t.Kind() == reflect.Ptr {
+		res := reflect.New(t.Elem())
+		if err := json.Unmarshal(data, res.Interface()); err != nil {
+			return nil, err
+		}
+		return res.Interface(), nil
+	}
+	res := reflect.New(t)
+	if err := json.Unmarshal(data, res.Interface()); err != nil {
+		return nil, err
+	}
+	return res.Elem().Interface(), nil
+}
@@ -0,0 +1,93 @@
+package fbmsgr
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	events := []Event{
+		MessageEvent{
+			MessageID:  "mid.1",
+			Body:       "hello",
+			SenderFBID: "5",
+			OtherUser:  "5",
+			Timestamp:  time.Unix(1500000000, 0).UTC(),
+			Tags:       []string{"source:chat:web"},
+			Mentions:   []Mention{{FBID: "6", Offset: 0, Length: 5}},
+			Attachments: []Attachment{
+				&ImageAttachment{FBID: "1", Width: 100, Height: 50, Animated: true},
+				&FileAttachment{Name: "report.pdf", FileURL: "https://example.com/r"},
+				&StickerAttachment{StickerID: 369239263222822, PackID: 1},
+				&LocationAttachment{Latitude: 1.5, Longitude: -2.25, Name: "Home"},
+				&UnknownAttachment{Type: "mystery", RawData: map[string]interface{}{
+					"key": "value",
+				}},
+			},
+			RawDelta: map[string]interface{}{"class": "NewMessage"},
+		},
+		UnknownEvent{
+			Type:  "delta",
+			Class: "SomethingNew",
+			Raw: map[string]interface{}{
+				"type":  "delta",
+				"delta": map[string]interface{}{"class": "SomethingNew"},
+			},
+		},
+		TypingEvent{SenderFBID: "5", Typing: true},
+	}
+
+	var buf bytes.Buffer
+	recorder := NewEventRecorder(&buf)
+	for _, evt := range events {
+		if err := recorder.Record(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recording := buf.String()
+
+	stream := ReplayEvents(bytes.NewReader([]byte(recording)), 0)
+	var replayed []Event
+	for evt := range stream.Chan() {
+		replayed = append(replayed, evt)
+	}
+	if err := stream.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, events) {
+		t.Errorf("expected %#v but got %#v", events, replayed)
+	}
+
+	for _, badLine := range []string{
+		"{not json\n",
+		`{"time":"2017-07-14T02:40:00Z","type":"NoSuchEvent","event":{}}` + "\n",
+	} {
+		badRecording := recording + badLine + recording
+		stream := ReplayEvents(bytes.NewReader([]byte(badRecording)), 0)
+		var count int
+		for range stream.Chan() {
+			count++
+		}
+		if stream.Error() == nil {
+			t.Errorf("%q: expected error", badLine)
+		}
+		if count != len(events) {
+			t.Errorf("%q: expected %d events but got %d", badLine, len(events), count)
+		}
+	}
+}
+
+func TestRecordUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewEventRecorder(&buf)
+	if err := recorder.Record(MessageEvent{
+		Attachments: []Attachment{nil},
+	}); err == nil {
+		t.Error("expected error for nil attachment")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
@@ -0,0 +1,320 @@
+package fbmsgr
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A ScheduledMessage is a message which a Scheduler will
+// send in the future.
+type ScheduledMessage struct {
+	// ID identifies the message in the Scheduler.
+	ID string `json:"id"`
+
+	Thread  ThreadID `json:"thread"`
+	Message *Message `json:"message"`
+
+	// Time is the next time the message will be sent.
+	Time time.Time `json:"time"`
+
+	// Recurrence is the cron expression for a recurring
+	// message, or "" for a message which is sent once.
+	Recurrence string `json:"recurrence,omitempty"`
+
+	// Attempts is the number of times sending the message
+	// has failed since it was last sent successfully.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// SchedulerOptions stores optional settings for a
+// Scheduler.
+type SchedulerOptions struct {
+	// MinBackoff and MaxBackoff bound the delay before a
+	// failed message is sent again, which doubles after
+	// every failure.
+	// If 0, default values are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnSend, if non-nil, is called from the Scheduler's
+	// Goroutine whenever it sends (or fails to send) a
+	// scheduled message.
+	OnSend func(msg *ScheduledMessage, msgID string, err error)
+}
+
+// A Scheduler sends messages at future times.
+//
+// Scheduled messages are saved to a file, so they will be
+// sent even if the process is restarted in the meantime.
+// A message whose time passed while no Scheduler was
+// running is sent as soon as the Scheduler is created.
+//
+// If a message cannot be sent, it is retried with
+// exponential backoff until it is sent or cancelled,
+// unless it is invalid and could never be sent.
+// A recurring message is retried until its next
+// occurrence, at which point the failed occurrence is
+// skipped.
+//
+// Recurring messages are scheduled with cron expressions,
+// which are evaluated in the local time zone.
+type Scheduler struct {
+	persistentQueue
+
+	options SchedulerOptions
+	state   schedulerState
+}
+
+type schedulerState struct {
+	Messages []*ScheduledMessage `json:"messages"`
+}
+
+// NewScheduler creates a Scheduler which stores its
+// scheduled messages in the file at path.
+//
+// If the file exists, the messages it contains are
+// loaded and scheduled.
+// If opts is nil, the default options are used.
+//
+// You must close the Scheduler when you are done with it.
+func NewScheduler(s *Session, path string, opts *SchedulerOptions) (sched *Scheduler,
+	err error) {
+	defer essentials.AddCtxTo("fbmsgr: new scheduler", &err)
+	if opts == nil {
+		opts = &SchedulerOptions{}
+	}
+	res := &Scheduler{options: *opts}
+	if err := res.open(s, path, &res.state, res.options.MinBackoff,
+		res.options.MaxBackoff); err != nil {
+		return nil, err
+	}
+	res.start(res.nextSend)
+	return res, nil
+}
+
+// Schedule arranges for a message to be sent once, at the
+// given time.
+//
+// Messages which could never be sent, such as empty
+// messages, are rejected immediately.
+// Otherwise, the message is saved to disk before Schedule
+// returns.
+// The resulting ID can be passed to Cancel.
+func (s *Scheduler) Schedule(thread ThreadID, msg *Message, t time.Time) (id string,
+	err error) {
+	defer essentials.AddCtxTo("fbmsgr: schedule message", &err)
+	return s.add(&ScheduledMessage{
+		Thread:  thread,
+		Message: msg,
+		Time:    t,
+	})
+}
+
+// ScheduleRecurring arranges for a message to be sent
+// repeatedly, according to a cron expression.
+//
+// The expression has five fields: minute, hour, day of
+// the month, month, and day of the week.
+// For example, "0 9 * * 1-5" sends the message at 9am on
+// every weekday.
+//
+// The message is saved to disk before ScheduleRecurring
+// returns.
+// The resulting ID can be passed to Cancel.
+func (s *Scheduler) ScheduleRecurring(thread ThreadID, msg *Message,
+	spec string) (id string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: schedule recurring message", &err)
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return "", err
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return "", errors.New("cron expression never matches")
+	}
+	return s.add(&ScheduledMessage{
+		Thread:     thread,
+		Message:    msg,
+		Time:       next,
+		Recurrence: spec,
+	})
+}
+
+// Cancel removes a scheduled message, so that it will not
+// be sent again.
+func (s *Scheduler) Cancel(id string) (err error) {
+	defer essentials.AddCtxTo("fbmsgr: cancel scheduled message", &err)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, msg := range s.state.Messages {
+		if msg.ID == id {
+			oldMessages := append([]*ScheduledMessage{}, s.state.Messages...)
+			s.removeMessage(msg)
+			if err := s.save(); err != nil {
+				s.state.Messages = oldMessages
+				return err
+			}
+			s.notify()
+			return nil
+		}
+	}
+	return errors.New("no scheduled message with ID: " + id)
+}
+
+// List returns the scheduled messages, sorted by the time
+// at which they will next be sent.
+func (s *Scheduler) List() []*ScheduledMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := make([]*ScheduledMessage, len(s.state.Messages))
+	for i, msg := range s.state.Messages {
+		msgCopy := *msg
+		res[i] = &msgCopy
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Time.Before(res[j].Time)
+	})
+	return res
+}
+
+// Close stops sending messages.
+//
+// Scheduled messages remain on disk, and will be sent by
+// the next Scheduler created with the same path.
+func (s *Scheduler) Close() error {
+	s.stop()
+	return nil
+}
+
+func (s *Scheduler) add(msg *ScheduledMessage) (string, error) {
+	if err := checkMessage(msg.Message); err != nil {
+		return "", err
+	}
+	msgCopy := *msg.Message
+	if msg.Recurrence != "" {
+		// Each occurrence gets its own offline threading ID
+		// when it is first sent.
+		msgCopy.OfflineThreadingID = ""
+	} else if msgCopy.OfflineThreadingID == "" {
+		msgCopy.OfflineThreadingID = s.session.randomMessageID()
+	}
+	msg.Message = &msgCopy
+	msg.ID = s.session.randomMessageID()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed() {
+		return "", errors.New("scheduler is closed")
+	}
+	s.state.Messages = append(s.state.Messages, msg)
+	if err := s.save(); err != nil {
+		s.state.Messages = s.state.Messages[:len(s.state.Messages)-1]
+		return "", err
+	}
+	s.notify()
+	return msg.ID, nil
+}
+
+func (s *Scheduler) nextSend() (time.Time, func()) {
+	msg := s.nextMessage()
+	if msg == nil {
+		return time.Time{}, nil
+	}
+	return msg.Time, func() {
+		s.sendMessage(msg)
+	}
+}
+
+func (s *Scheduler) nextMessage() *ScheduledMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var res *ScheduledMessage
+	for _, msg := range s.state.Messages {
+		if res == nil || msg.Time.Before(res.Time) {
+			res = msg
+		}
+	}
+	return res
+}
+
+func (s *Scheduler) sendMessage(msg *ScheduledMessage) {
+	s.lock.Lock()
+	if !s.hasMessage(msg) {
+		// The message was cancelled while we were waiting.
+		s.lock.Unlock()
+		return
+	}
+	if msg.Message != nil && msg.Recurrence != "" && msg.Attempts == 0 {
+		// Retries of an occurrence share an offline
+		// threading ID, so they will not duplicate it.
+		// The Message is replaced rather than modified,
+		// since List may have returned it.
+		msgCopy := *msg.Message
+		msgCopy.OfflineThreadingID = s.session.randomMessageID()
+		msg.Message = &msgCopy
+		s.save()
+	}
+	outgoing := msg.Message
+	failures := msg.Attempts
+	s.lock.Unlock()
+
+	sendRes := s.send(msg.Thread, outgoing, failures)
+	if sendRes.Stopped {
+		return
+	}
+
+	s.lock.Lock()
+	if sendRes.Err != nil {
+		msg.Attempts++
+	}
+	sentCopy := *msg
+	if s.hasMessage(msg) {
+		var next time.Time
+		if msg.Recurrence != "" && (sendRes.Err == nil || !sendRes.Retry.IsZero()) {
+			if schedule, err := parseCron(msg.Recurrence); err == nil {
+				next = schedule.Next(time.Now())
+			}
+		}
+		if !sendRes.Retry.IsZero() && (next.IsZero() || sendRes.Retry.Before(next)) {
+			next = sendRes.Retry
+		} else {
+			msg.Attempts = 0
+		}
+		if next.IsZero() {
+			s.removeMessage(msg)
+		} else {
+			msg.Time = next
+		}
+		s.saveAfterSend()
+	}
+	s.lock.Unlock()
+
+	if s.options.OnSend != nil {
+		s.options.OnSend(&sentCopy, sendRes.MessageID, sendRes.Err)
+	}
+}
+
+// hasMessage checks if a message is still scheduled.
+// The caller must hold s.lock.
+func (s *Scheduler) hasMessage(msg *ScheduledMessage) bool {
+	for _, x := range s.state.Messages {
+		if x == msg {
+			return true
+		}
+	}
+	return false
+}
+
+// removeMessage removes a message from the state.
+// The caller must hold s.lock.
+func (s *Scheduler) removeMessage(msg *ScheduledMessage) {
+	for i, x := range s.state.Messages {
+		if x == msg {
+			s.state.Messages = append(s.state.Messages[:i], s.state.Messages[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package fbmsgr
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fbmsgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scheduled.json")
+
+	var lock sync.Mutex
+	var otids []string
+	session := testSession(t, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if r.URL.Path != "/messaging/send/" {
+			http.NotFound(w, r)
+			return
+		}
+		lock.Lock()
+		otids = append(otids, r.FormValue("offline_threading_id"))
+		numAttempts := len(otids)
+		lock.Unlock()
+		if numAttempts < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`for (;;);{"payload":{"actions":[{"message_id":"mid.1"}]}}`))
+	}))
+
+	type sendResult struct {
+		Attempts int
+		MsgID    string
+		Err      error
+	}
+	results := make(chan sendResult, 10)
+	sched, err := NewScheduler(session, path, &SchedulerOptions{
+		MinBackoff: time.Millisecond * 10,
+		MaxBackoff: time.Millisecond * 20,
+		OnSend: func(msg *ScheduledMessage, msgID string, err error) {
+			results <- sendResult{msg.Attempts, msgID, err}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Close()
+
+	if _, err := sched.Schedule(UserThreadID("5"), &Message{}, time.Now()); err == nil {
+		t.Error("expected error for empty message")
+	}
+	if _, err := sched.Schedule(UserThreadID("5"), &Message{Body: "hi"},
+		time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		var res sendResult
+		select {
+		case res = <-results:
+		case <-time.After(testTimeout):
+			t.Fatal("timed out")
+		}
+		if i < 3 {
+			if res.Err == nil || res.Attempts != i {
+				t.Errorf("attempt %d: unexpected result: %+v", i, res)
+			}
+			// The failed message should still be scheduled.
+			if len(sched.List()) != 1 {
+				t.Errorf("attempt %d: message was removed", i)
+			}
+		} else if res.Err != nil || res.MsgID != "mid.1" {
+			t.Errorf("attempt %d: unexpected result: %+v", i, res)
+		}
+	}
+	if n := len(sched.List()); n != 0 {
+		t.Errorf("unexpected scheduled messages: %d", n)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, otid := range otids {
+		if otid == "" || otid != otids[0] {
+			t.Errorf("attempts should share an offline threading ID: %q", otids)
+			break
+		}
+	}
+}
+
+func TestSchedulerInvalidLoadedMessage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fbmsgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scheduled.json")
+	data := `{"messages":[` +
+		`{"id":"1","thread":{"FBID":"2"},"message":null,"time":"2000-01-01T00:00:00Z"},` +
+		`{"id":"2","thread":{"FBID":"2"},"time":"2000-01-01T00:00:00Z",` +
+		`"recurrence":"* * * * *"},` +
+		`{"id":"3","thread":{"FBID":"2"},"message":{"Body":""},` +
+		`"time":"2000-01-01T00:00:00Z","recurrence":"* * * * *"}]}`
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan error, 10)
+	session := testSession(t, http.NotFoundHandler())
+	sched, err := NewScheduler(session, path, &SchedulerOptions{
+		OnSend: func(msg *ScheduledMessage, msgID string, err error) {
+			results <- err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sched.Close()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-results:
+			if err == nil {
+				t.Error("expected error")
+			}
+		case <-time.After(testTimeout):
+			t.Fatal("timed out")
+		}
+	}
+	if n := len(sched.List()); n != 0 {
+		t.Errorf("unexpected scheduled messages: %d", n)
+	}
+}
@@ -1,28 +1,29 @@
 package fbmsgr
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"io"
-	"mime"
-	"mime/multipart"
-	"net/http"
-	"net/textproto"
 	"net/url"
-	"os"
-	"path"
 	"strconv"
 	"time"
 
 	"github.com/unixpickle/essentials"
 )
 
+// An EmojiSize is the size of a hot-like emoji.
 type EmojiSize string
 
+// These are the sizes Messenger accepts for hot likes.
+//
+// All three constants have type EmojiSize.
+// MediumEmoji and LargeEmoji used to be untyped string
+// constants, so code which assigns them to a string
+// variable needs an explicit string conversion.
 const (
 	SmallEmoji  EmojiSize = "small"
-	MediumEmoji           = "medium"
-	LargeEmoji            = "large"
+	MediumEmoji EmojiSize = "medium"
+	LargeEmoji  EmojiSize = "large"
 )
 
 // UploadResult is the result of uploading a file.
@@ -35,62 +36,203 @@ type UploadResult struct {
 	ImageID string
 }
 
+// A ThreadID identifies a chat thread, which is either a
+// one-on-one chat with a user or a group chat.
+type ThreadID struct {
+	// FBID is the other user's FBID for a one-on-one chat,
+	// or the thread's FBID for a group chat.
+	FBID string
+
+	// Group is true if the thread is a group chat.
+	Group bool
+}
+
+// UserThreadID creates a ThreadID for a one-on-one chat
+// with the given user.
+func UserThreadID(fbid string) ThreadID {
+	return ThreadID{FBID: fbid}
+}
+
+// GroupThreadID creates a ThreadID for a group chat.
+func GroupThreadID(fbid string) ThreadID {
+	return ThreadID{FBID: fbid, Group: true}
+}
+
+// addToParams sets the request parameters which specify
+// the thread as a message's receiver.
+func (t ThreadID) addToParams(values url.Values) {
+	if t.Group {
+		values.Set("thread_fbid", t.FBID)
+	} else {
+		values.Set("other_user_fbid", t.FBID)
+	}
+}
+
+// A Mention refers to a user in a message's body.
+type Mention struct {
+	// FBID is the mentioned user's FBID.
+	FBID string
+
+	// Offset and Length specify the part of the body that
+	// refers to the user, measured in UTF-16 code units.
+	Offset int
+	Length int
+}
+
+// A Location is a place on a map which can be shared in a
+// message.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+
+	// Name, if non-empty, is a place name to show with
+	// the location.
+	Name string
+
+	// Current indicates that the location is the sender's
+	// current location, rather than a pin they placed.
+	Current bool
+}
+
+// A Message stores the contents of an outgoing message.
+//
+// A message must have a body, attachments, a sticker, a
+// location, or a shared link.
+type Message struct {
+	// Body is the text of the message.
+	Body string
+
+	// Attachments contains uploaded files to attach to the
+	// message.
+	// Different kinds of attachments (e.g. images and
+	// files) may be mixed in one message, in which case the
+	// Body serves as a caption.
+	Attachments []*UploadResult
+
+	// StickerID, if non-zero, is the ID of a sticker to
+	// send with the message.
+	StickerID int64
+
+	// Location, if non-nil, is a location to share in the
+	// message.
+	Location *Location
+
+	// ShareURL, if non-empty, is a link to share in the
+	// message.
+	// Its preview is fetched from the server and attached
+	// to the message.
+	// If Body is empty, the link is used as the body.
+	ShareURL string
+
+	// Tags contains extra tags for the message, such as
+	// "hot_emoji_source:hot_like".
+	Tags []string
+
+	// ReplyTo, if non-empty, is the ID of a message that
+	// this message replies to.
+	ReplyTo string
+
+	// Mentions contains users who are mentioned in the
+	// message's body.
+	Mentions []Mention
+
+	// OfflineThreadingID, if non-empty, is used as the
+	// client-generated ID for the message instead of a
+	// random one.
+	// Sending a message twice with the same ID allows the
+	// server to discard the duplicate.
+	OfflineThreadingID string
+
+	// EphemeralTTL, if set, makes the message disappear
+	// after the given amount of time.
+	// It must be one of the supported EphemeralTTL values.
+	EphemeralTTL EphemeralTTL
+}
+
+// Send sends a message to a user or a group chat.
+func (s *Session) Send(thread ThreadID, msg *Message) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send", &err)
+	return s.send(thread, msg)
+}
+
 // SendText attempts to send a textual message to the user
 // with the given fbid.
 func (s *Session) SendText(fbid, message string) (msgID string, err error) {
 	defer essentials.AddCtxTo("fbmsgr: send text", &err)
-
-	reqParams, err := s.textMessageParams(message)
-	if err != nil {
-		return "", err
-	}
-	reqParams.Add("other_user_fbid", fbid)
-	return s.sendMessage(reqParams)
+	return s.send(UserThreadID(fbid), &Message{Body: message})
 }
 
 // SendGroupText is like SendText, but the message is sent
 // to a group chat rather than to an individual.
 func (s *Session) SendGroupText(groupFBID, message string) (msgID string, err error) {
 	defer essentials.AddCtxTo("fbmsgr: send group text", &err)
-
-	reqParams, err := s.textMessageParams(message)
-	if err != nil {
-		return "", err
-	}
-	reqParams.Add("thread_fbid", groupFBID)
-	return s.sendMessage(reqParams)
+	return s.send(GroupThreadID(groupFBID), &Message{Body: message})
 }
 
 // SendLike is like SendText, but it sends an emoji at a
 // given size.
-// It is crutial that the emoji is valid and that the size
-// is also valid.
-// Otherwise, this can trigger a bug in the web client
-// that essentially bricks the conversation.
+// The emoji must be accepted by IsLikeEmoji and the size
+// must be valid.
+// Otherwise, the message is not sent and the underlying
+// error is an *InvalidLikeError, since such a message can
+// trigger a bug in the web client that essentially bricks
+// the conversation.
 func (s *Session) SendLike(fbid, emoji string, size EmojiSize) (msgID string, err error) {
 	defer essentials.AddCtxTo("fbmsgr: send like", &err)
-
-	reqParams, err := s.textMessageParams(emoji)
-	if err != nil {
-		return "", err
-	}
-	reqParams.Add("other_user_fbid", fbid)
-	reqParams.Add("tags[0]", "hot_emoji_size:"+string(size))
-	reqParams.Add("tags[1]", "hot_emoji_source:hot_like")
-	return s.sendMessage(reqParams)
+	return s.send(UserThreadID(fbid), likeMessage(emoji, size))
 }
 
 // SendGroupLike is like SendLike, but for a group thread.
 func (s *Session) SendGroupLike(groupFBID, emoji string, size EmojiSize) (msgID string, err error) {
 	defer essentials.AddCtxTo("fbmsgr: send group like", &err)
+	return s.send(GroupThreadID(groupFBID), likeMessage(emoji, size))
+}
 
-	reqParams, err := s.textMessageParams(emoji)
+// SendSticker sends a sticker to a user.
+//
+// Sticker IDs can be found in incoming StickerAttachments
+// or by listing a pack with StickerPack.
+func (s *Session) SendSticker(fbid string, stickerID int64) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send sticker", &err)
+	return s.send(UserThreadID(fbid), &Message{StickerID: stickerID})
+}
+
+// SendGroupSticker is like SendSticker, but for a group
+// thread.
+func (s *Session) SendGroupSticker(groupFBID string, stickerID int64) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send group sticker", &err)
+	return s.send(GroupThreadID(groupFBID), &Message{StickerID: stickerID})
+}
+
+// SendLocation shares a location with a user or a group
+// chat.
+func (s *Session) SendLocation(thread ThreadID, loc *Location) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send location", &err)
+	return s.send(thread, &Message{Location: loc})
+}
+
+// SendLink shares a link, along with its preview, with a
+// user or a group chat.
+func (s *Session) SendLink(thread ThreadID, link string) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send link", &err)
+	return s.send(thread, &Message{ShareURL: link})
+}
+
+// Forward re-shares an existing message, including its
+// attachments, to a user or a group chat.
+//
+// The attachments are shared by reference, so they do not
+// need to be downloaded or uploaded again.
+func (s *Session) Forward(messageID string, thread ThreadID) (msgID string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: forward", &err)
+
+	reqParams, err := s.textMessageParams("")
 	if err != nil {
 		return "", err
 	}
-	reqParams.Add("thread_fbid", groupFBID)
-	reqParams.Add("tags[0]", "hot_emoji_size:"+string(size))
-	reqParams.Add("tags[1]", "hot_emoji_source:hot_like")
+	reqParams.Del("body")
+	reqParams.Set("forwarded_msg_id", messageID)
+	thread.addToParams(reqParams)
 	return s.sendMessage(reqParams)
 }
 
@@ -129,122 +271,133 @@ func (s *Session) SendGroupTyping(groupFBID string, typing bool) (err error) {
 // For group chats, use SendGroupAttachment.
 func (s *Session) SendAttachment(userFBID string, a *UploadResult) (mid string, err error) {
 	defer essentials.AddCtxTo("fbmsgr: send attachment", &err)
-	reqParams, err := s.attachmentMessageParams(a)
-	if err != nil {
-		return "", err
-	}
-	reqParams.Add("other_user_fbid", userFBID)
-	return s.sendMessage(reqParams)
+	return s.send(UserThreadID(userFBID), &Message{Attachments: []*UploadResult{a}})
 }
 
 // SendGroupAttachment is like SendAttachment for groups.
 func (s *Session) SendGroupAttachment(groupFBID string, a *UploadResult) (mid string, err error) {
 	defer essentials.AddCtxTo("fbmsgr: send group attachment", &err)
-	reqParams, err := s.attachmentMessageParams(a)
-	if err != nil {
-		return "", err
-	}
-	reqParams.Add("thread_fbid", groupFBID)
-	return s.sendMessage(reqParams)
+	return s.send(GroupThreadID(groupFBID), &Message{Attachments: []*UploadResult{a}})
 }
 
-// Upload uploads a file to be sent as an attachment.
-func (s *Session) Upload(filename string, file io.Reader) (res *UploadResult, err error) {
-	defer essentials.AddCtxTo("fbmsgr: upload", &err)
+func (s *Session) sendTyping(thread, to string, typ bool) error {
+	url := BaseURL + "/ajax/messaging/typ.php?dpr=1"
 	values, err := s.commonParams()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	values.Set("dpr", "1")
+	values.Set("source", "source:messenger:web")
+	values.Set("thread", thread)
+	values.Set("to", to)
+	if typ {
+		values.Set("typ", "1")
+	} else {
+		values.Set("typ", "0")
+	}
+	_, err = s.jsonForPost(url, values)
+	return err
+}
+
+func (s *Session) send(thread ThreadID, msg *Message) (string, error) {
+	return s.sendContext(context.Background(), thread, msg)
+}
 
-	reader, writer, err := os.Pipe()
+// sendContext is like send, but it gives up waiting for
+// the rate limit if ctx is done.
+func (s *Session) sendContext(ctx context.Context, thread ThreadID,
+	msg *Message) (string, error) {
+	reqParams, err := s.messageParams(msg)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer reader.Close()
-	mp := multipart.NewWriter(writer)
+	thread.addToParams(reqParams)
+	return s.sendMessageContext(ctx, reqParams)
+}
 
-	url := BaseURL + "/ajax/mercury/upload.php?" + values.Encode()
-	req, err := http.NewRequest("POST", url, reader)
-	if err != nil {
+func (s *Session) messageParams(msg *Message) (url.Values, error) {
+	if err := checkMessage(msg); err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", mp.FormDataContentType())
-
-	errChan := make(chan error, 1)
-	go func() {
-		var err error
-		defer func() {
-			if err != nil {
-				errChan <- err
-			}
-			closeErr := mp.Close()
-			if err == nil && closeErr != nil {
-				errChan <- closeErr
-			}
-			close(errChan)
-			writer.Close()
-		}()
-		header := textproto.MIMEHeader{}
-		ext := path.Ext(filename)
-		header.Set("Content-Disposition", "form-data; name=\"upload_1000\"; filename=\"file"+
-			ext+"\"")
-		header.Set("Content-Type", mime.TypeByExtension(ext))
-		sender, err := mp.CreatePart(header)
-		if err != nil {
-			return
-		}
-		_, err = io.Copy(sender, file)
-	}()
 
-	body, err := jsonForResp(s.Client.Do(req))
-	if err != nil {
-		return nil, err
+	var reqParams url.Values
+	var err error
+	if len(msg.Attachments) > 0 {
+		reqParams, err = s.attachmentMessageParams(msg.Body, msg.Attachments)
+	} else {
+		reqParams, err = s.textMessageParams(msg.Body)
 	}
-	if err := <-errChan; err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	var msg struct {
-		Payload struct {
-			Meta []struct {
-				VideoID float64 `json:"video_id"`
-				FileID  float64 `json:"file_id"`
-				AudioID float64 `json:"audio_id"`
-				ImageID float64 `json:"image_id"`
-			} `json:"metadata"`
-		} `json:"payload"`
+	if msg.OfflineThreadingID != "" {
+		reqParams.Set("message_id", msg.OfflineThreadingID)
+		reqParams.Set("offline_threading_id", msg.OfflineThreadingID)
+		s.sentIDs.Add(msg.OfflineThreadingID)
 	}
-	if err := json.Unmarshal(body, &msg); err != nil {
-		return nil, err
+	reqParams.Set("ephemeral_ttl_mode", strconv.Itoa(int(msg.EphemeralTTL)))
+	if msg.StickerID != 0 {
+		reqParams.Set("sticker_id", strconv.FormatInt(msg.StickerID, 10))
+		reqParams.Set("has_attachment", "true")
+	}
+	if msg.Location != nil {
+		if msg.Body == "" {
+			reqParams.Del("body")
+		}
+		reqParams.Set("has_attachment", "true")
+		loc := msg.Location
+		reqParams.Set("location_attachment[coordinates][latitude]",
+			strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+		reqParams.Set("location_attachment[coordinates][longitude]",
+			strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+		reqParams.Set("location_attachment[is_current_location]",
+			strconv.FormatBool(loc.Current))
+		if loc.Name != "" {
+			reqParams.Set("location_attachment[name]", loc.Name)
+		}
+	}
+	if msg.ShareURL != "" {
+		if msg.Body == "" {
+			reqParams.Set("body", msg.ShareURL)
+		}
+		if err := s.addShareParams(reqParams, msg.ShareURL); err != nil {
+			return nil, err
+		}
+	}
+	for i, tag := range msg.Tags {
+		reqParams.Set("tags["+strconv.Itoa(i)+"]", tag)
 	}
-	if len(msg.Payload.Meta) != 1 {
-		return nil, errors.New("unexpected result")
+	if msg.ReplyTo != "" {
+		reqParams.Set("replied_to_message_id", msg.ReplyTo)
 	}
-	return &UploadResult{
-		VideoID: floatIDToString(msg.Payload.Meta[0].VideoID),
-		AudioID: floatIDToString(msg.Payload.Meta[0].AudioID),
-		ImageID: floatIDToString(msg.Payload.Meta[0].ImageID),
-		FileID:  floatIDToString(msg.Payload.Meta[0].FileID),
-	}, nil
+	for i, mention := range msg.Mentions {
+		prefix := "profile_xmd[" + strconv.Itoa(i) + "]"
+		reqParams.Set(prefix+"[id]", mention.FBID)
+		reqParams.Set(prefix+"[offset]", strconv.Itoa(mention.Offset))
+		reqParams.Set(prefix+"[length]", strconv.Itoa(mention.Length))
+		reqParams.Set(prefix+"[type]", "p")
+	}
+	return reqParams, nil
 }
 
-func (s *Session) sendTyping(thread, to string, typ bool) error {
-	url := BaseURL + "/ajax/messaging/typ.php?dpr=1"
-	values, err := s.commonParams()
-	if err != nil {
+// checkMessage finds problems which would prevent a
+// message from ever being sent.
+func checkMessage(msg *Message) error {
+	if msg == nil {
+		return errors.New("missing message")
+	}
+	if msg.Body == "" && len(msg.Attachments) == 0 && msg.StickerID == 0 &&
+		msg.Location == nil && msg.ShareURL == "" {
+		return errors.New("empty message")
+	}
+	if err := checkHotLike(msg); err != nil {
 		return err
 	}
-	values.Set("source", "source:messenger:web")
-	values.Set("thread", thread)
-	values.Set("to", to)
-	if typ {
-		values.Set("typ", "1")
-	} else {
-		values.Set("type", "0")
+	if !msg.EphemeralTTL.Supported() {
+		return errors.New("unsupported ephemeral TTL: " +
+			strconv.Itoa(int(msg.EphemeralTTL)))
 	}
-	_, err = s.jsonForPost(url, values)
-	return err
+	return nil
 }
 
 func (s *Session) textMessageParams(body string) (url.Values, error) {
@@ -260,6 +413,7 @@ func (s *Session) textMessageParams(body string) (url.Values, error) {
 	msgID := s.randomMessageID()
 	reqParams.Add("message_id", msgID)
 	reqParams.Add("offline_threading_id", msgID)
+	s.sentIDs.Add(msgID)
 	reqParams.Add("source", "source:messenger:web")
 
 	timestamp := time.Now().UnixNano() / 1000000
@@ -269,28 +423,52 @@ func (s *Session) textMessageParams(body string) (url.Values, error) {
 	return reqParams, nil
 }
 
-func (s *Session) attachmentMessageParams(a *UploadResult) (url.Values, error) {
-	values, err := s.textMessageParams("")
+// attachmentMessageParams creates the parameters for a
+// message with one or more attachments and an optional
+// caption.
+func (s *Session) attachmentMessageParams(caption string,
+	attachments []*UploadResult) (url.Values, error) {
+	values, err := s.textMessageParams(caption)
 	if err != nil {
 		return nil, err
 	}
-	values.Del("body")
+	if caption == "" {
+		values.Del("body")
+	}
 	values.Set("has_attachment", "true")
-	if a.FileID != "" {
-		values.Set("file_ids[0]", a.FileID)
-	} else if a.AudioID != "" {
-		values.Set("audio_ids[0]", a.AudioID)
-	} else if a.ImageID != "" {
-		values.Set("image_ids[0]", a.ImageID)
-	} else if a.VideoID != "" {
-		values.Set("video_ids[0]", a.VideoID)
-	} else {
-		return nil, errors.New("no attachment ID")
+	counts := map[string]int{}
+	for _, a := range attachments {
+		var key, id string
+		if a.FileID != "" {
+			key, id = "file_ids", a.FileID
+		} else if a.AudioID != "" {
+			key, id = "audio_ids", a.AudioID
+		} else if a.ImageID != "" {
+			key, id = "image_ids", a.ImageID
+		} else if a.VideoID != "" {
+			key, id = "video_ids", a.VideoID
+		} else {
+			return nil, errors.New("no attachment ID")
+		}
+		values.Set(key+"["+strconv.Itoa(counts[key])+"]", id)
+		counts[key]++
 	}
 	return values, nil
 }
 
 func (s *Session) sendMessage(values url.Values) (mid string, err error) {
+	return s.sendMessageContext(context.Background(), values)
+}
+
+func (s *Session) sendMessageContext(ctx context.Context, values url.Values) (mid string,
+	err error) {
+	thread := "group:" + values.Get("thread_fbid")
+	if values.Get("thread_fbid") == "" {
+		thread = "user:" + values.Get("other_user_fbid")
+	}
+	if err := s.waitRateLimit(ctx, thread); err != nil {
+		return "", err
+	}
 	response, err := s.jsonForPost(BaseURL+"/messaging/send/?dpr=1", values)
 	if err != nil {
 		return "", err
@@ -311,6 +489,13 @@ func (s *Session) sendMessage(values url.Values) (mid string, err error) {
 	return "", errors.New("no message ID in response")
 }
 
+func likeMessage(emoji string, size EmojiSize) *Message {
+	return &Message{
+		Body: emoji,
+		Tags: []string{hotLikeSizeTag + string(size), hotLikeSourceTag},
+	}
+}
+
 func (s *Session) randomMessageID() string {
 	res := "6"
 
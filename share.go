@@ -0,0 +1,75 @@
+package fbmsgr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// addShareParams fetches the preview for a link and adds
+// it to the parameters of an outgoing message.
+func (s *Session) addShareParams(values url.Values, link string) error {
+	params, err := s.commonParams()
+	if err != nil {
+		return err
+	}
+	params.Set("uri", link)
+	params.Set("image_height", "960")
+	params.Set("image_width", "960")
+	response, err := s.jsonForPost(BaseURL+"/message_share_attachment/fromURI/?dpr=1",
+		params)
+	if err != nil {
+		return err
+	}
+	var obj struct {
+		Payload struct {
+			ShareData struct {
+				ShareType   interface{}            `json:"share_type"`
+				ShareParams map[string]interface{} `json:"share_params"`
+			} `json:"share_data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(response, &obj); err != nil {
+		return err
+	}
+	shareData := obj.Payload.ShareData
+	shareType := formParamValue(shareData.ShareType)
+	if shareType == "" || shareData.ShareParams == nil {
+		return errors.New("no preview for link: " + link)
+	}
+	values.Set("has_attachment", "true")
+	values.Set("shareable_attachment[share_type]", shareType)
+	addNestedParams(values, "shareable_attachment[share_params]", shareData.ShareParams)
+	return nil
+}
+
+// addNestedParams encodes a JSON value as form parameters
+// using PHP's bracket syntax.
+func addNestedParams(values url.Values, prefix string, val interface{}) {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		for key, x := range val {
+			addNestedParams(values, prefix+"["+key+"]", x)
+		}
+	case []interface{}:
+		for i, x := range val {
+			addNestedParams(values, prefix+"["+strconv.Itoa(i)+"]", x)
+		}
+	case nil:
+	default:
+		values.Set(prefix, formParamValue(val))
+	}
+}
+
+func formParamValue(val interface{}) string {
+	switch val := val.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return ""
+}
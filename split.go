@@ -0,0 +1,330 @@
+package fbmsgr
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/unixpickle/essentials"
+)
+
+// DefaultMaxMessageLength is the default maximum length
+// of a message body for SendSplit, measured in UTF-16 code
+// units.
+const DefaultMaxMessageLength = 20000
+
+// SplitOptions controls how SendSplit divides a message.
+type SplitOptions struct {
+	// MaxLength is the maximum length of each part,
+	// including its number, in UTF-16 code units.
+	// If 0, DefaultMaxMessageLength is used.
+	MaxLength int
+
+	// NoNumbering disables the "(1/3) " prefixes that are
+	// otherwise added to each part.
+	NoNumbering bool
+}
+
+// SendSplit sends a message, splitting its body into
+// several messages if it is too long.
+//
+// The body is split at paragraph, line, sentence, or word
+// boundaries where possible, and never inside of a
+// character or a mention.
+// Each part is numbered, and the parts are sent in order.
+// Attachments, stickers, locations, shared links, and
+// reply targets are sent with the first part.
+//
+// The resulting IDs are for the messages that were sent
+// successfully, even if an error occurs.
+// If opts is nil, the default options are used.
+func (s *Session) SendSplit(thread ThreadID, msg *Message,
+	opts *SplitOptions) (msgIDs []string, err error) {
+	defer essentials.AddCtxTo("fbmsgr: send split", &err)
+	for _, part := range splitMessage(msg, opts) {
+		msgID, err := s.send(thread, part)
+		if err != nil {
+			return msgIDs, err
+		}
+		msgIDs = append(msgIDs, msgID)
+	}
+	return msgIDs, nil
+}
+
+// splitMessage divides a message into numbered parts.
+func splitMessage(msg *Message, opts *SplitOptions) []*Message {
+	if opts == nil {
+		opts = &SplitOptions{}
+	}
+	maxLength := opts.MaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxMessageLength
+	}
+
+	clusters := graphemeClusters(msg.Body)
+	if clusterUnits(clusters) <= maxLength {
+		return []*Message{msg}
+	}
+
+	var chunks []bodyChunk
+	var prefixLen func(i, n int) int
+	if opts.NoNumbering {
+		prefixLen = func(i, n int) int { return 0 }
+		chunks = splitClusters(clusters, maxLength, msg.Mentions)
+	} else {
+		prefixLen = func(i, n int) int { return utf16Len(partPrefix(i, n)) }
+		// The number of parts determines the length of the
+		// prefixes, which in turn affects the number of parts.
+		numParts := 1
+		for {
+			chunks = splitClusters(clusters, maxLength-prefixLen(numParts, numParts),
+				msg.Mentions)
+			if len(strconv.Itoa(len(chunks))) <= len(strconv.Itoa(numParts)) {
+				break
+			}
+			numParts = len(chunks)
+		}
+	}
+
+	var res []*Message
+	for i, chunk := range chunks {
+		part := &Message{
+			Body: chunk.Text,
+			Tags: msg.Tags,
+
+			EphemeralTTL: msg.EphemeralTTL,
+		}
+		if !opts.NoNumbering {
+			part.Body = partPrefix(i+1, len(chunks)) + part.Body
+		}
+		if i == 0 {
+			part.Attachments = msg.Attachments
+			part.StickerID = msg.StickerID
+			part.Location = msg.Location
+			part.ShareURL = msg.ShareURL
+			part.ReplyTo = msg.ReplyTo
+			part.OfflineThreadingID = msg.OfflineThreadingID
+		}
+		offset := prefixLen(i+1, len(chunks)) - chunk.Offset
+		for _, mention := range msg.Mentions {
+			if mention.Offset >= chunk.Offset &&
+				mention.Offset+mention.Length <= chunk.Offset+chunk.Length {
+				mention.Offset += offset
+				part.Mentions = append(part.Mentions, mention)
+			}
+		}
+		res = append(res, part)
+	}
+	return res
+}
+
+func partPrefix(i, n int) string {
+	return "(" + strconv.Itoa(i) + "/" + strconv.Itoa(n) + ") "
+}
+
+// A bodyChunk is a piece of a message body.
+// Its Offset and Length are measured in UTF-16 code units
+// relative to the original body.
+type bodyChunk struct {
+	Text   string
+	Offset int
+	Length int
+}
+
+// splitClusters divides a sequence of grapheme clusters
+// into chunks with a maximum length, preferring to split
+// at paragraph, line, sentence, and word boundaries.
+//
+// Whitespace at the boundaries of chunks is removed.
+func splitClusters(clusters []string, maxLength int, mentions []Mention) []bodyChunk {
+	if maxLength < 1 {
+		maxLength = 1
+	}
+
+	// offsets[i] is the UTF-16 offset of the i-th cluster.
+	offsets := make([]int, len(clusters)+1)
+	for i, c := range clusters {
+		offsets[i+1] = offsets[i] + utf16Len(c)
+	}
+	insideMention := func(idx int) bool {
+		for _, m := range mentions {
+			if offsets[idx] > m.Offset && offsets[idx] < m.Offset+m.Length {
+				return true
+			}
+		}
+		return false
+	}
+
+	var res []bodyChunk
+	start := 0
+	for {
+		for start < len(clusters) && isSpaceCluster(clusters[start]) {
+			start++
+		}
+		if start == len(clusters) {
+			return res
+		}
+
+		end := start
+		for end < len(clusters) && offsets[end+1]-offsets[start] <= maxLength {
+			end++
+		}
+		if end == start {
+			// A single cluster is longer than the limit.
+			end++
+		}
+		if end < len(clusters) {
+			end = chooseBreak(clusters, start, end, insideMention)
+		}
+
+		trimmedEnd := end
+		for trimmedEnd > start && isSpaceCluster(clusters[trimmedEnd-1]) {
+			trimmedEnd--
+		}
+		res = append(res, bodyChunk{
+			Text:   strings.Join(clusters[start:trimmedEnd], ""),
+			Offset: offsets[start],
+			Length: offsets[trimmedEnd] - offsets[start],
+		})
+		start = end
+	}
+}
+
+// chooseBreak finds the best place to end a chunk that
+// starts at cluster index start and may extend up to (but
+// not including) index end.
+//
+// A boundary may be used either after or before the
+// whitespace that marks it, since whitespace at the ends
+// of chunks is removed anyway.
+func chooseBreak(clusters []string, start, end int, insideMention func(int) bool) int {
+	isNewline := func(i int) bool {
+		return i >= start && i < len(clusters) && isNewlineCluster(clusters[i])
+	}
+	isParagraph := func(i int) bool {
+		return (isNewline(i-1) && isNewline(i-2)) || (isNewline(i) && isNewline(i+1))
+	}
+	isLine := func(i int) bool {
+		return isNewline(i-1) || isNewline(i)
+	}
+	isSentenceEnd := func(i int) bool {
+		return i >= start && strings.ContainsAny(clusters[i], ".!?")
+	}
+	isSentence := func(i int) bool {
+		return (isSpaceCluster(clusters[i-1]) && isSentenceEnd(i-2)) ||
+			(isSentenceEnd(i-1) && isSpaceCluster(clusters[i]))
+	}
+	isWord := func(i int) bool {
+		return isSpaceCluster(clusters[i-1]) || isSpaceCluster(clusters[i])
+	}
+
+	// Only use coarse boundaries if they do not make the
+	// chunk too short.
+	minEnd := start + (end-start)/2
+	for _, check := range []func(int) bool{isParagraph, isLine, isSentence} {
+		for i := end; i > minEnd; i-- {
+			if check(i) && !insideMention(i) {
+				return i
+			}
+		}
+	}
+	for i := end; i > start; i-- {
+		if isWord(i) && !insideMention(i) {
+			return i
+		}
+	}
+	for i := end; i > start; i-- {
+		if !insideMention(i) {
+			return i
+		}
+	}
+	return end
+}
+
+// graphemeClusters divides a string into user-perceived
+// characters.
+//
+// This approximates Unicode's extended grapheme clusters,
+// keeping combining marks, variation selectors, emoji
+// modifiers, zero-width joiner sequences, flags, and CRLF
+// pairs together.
+func graphemeClusters(s string) []string {
+	var res []string
+	var current []rune
+	var regionalCount int
+	for _, r := range s {
+		if len(current) > 0 && !extendsCluster(current, r, regionalCount) {
+			res = append(res, string(current))
+			current = nil
+			regionalCount = 0
+		}
+		current = append(current, r)
+		if isRegionalIndicator(r) {
+			regionalCount++
+		}
+	}
+	if len(current) > 0 {
+		res = append(res, string(current))
+	}
+	return res
+}
+
+func extendsCluster(cluster []rune, r rune, regionalCount int) bool {
+	last := cluster[len(cluster)-1]
+	switch {
+	case last == '\r' && r == '\n':
+		return true
+	case last == '\u200d':
+		return true
+	case r == '\u200d':
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xfe00 && r <= 0xfe0f, r >= 0xe0100 && r <= 0xe01ef:
+		// Variation selectors.
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff:
+		// Emoji skin tone modifiers.
+		return true
+	case r >= 0xe0020 && r <= 0xe007f:
+		// Tag characters, used in subdivision flags.
+		return true
+	case isRegionalIndicator(r) && isRegionalIndicator(last):
+		return regionalCount%2 == 1
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+func isNewlineCluster(c string) bool {
+	return c == "\n" || c == "\r\n"
+}
+
+func isSpaceCluster(c string) bool {
+	return strings.TrimSpace(c) == ""
+}
+
+func clusterUnits(clusters []string) int {
+	var res int
+	for _, c := range clusters {
+		res += utf16Len(c)
+	}
+	return res
+}
+
+// utf16Len computes the length of a string in UTF-16 code
+// units, which is how Messenger measures text.
+func utf16Len(s string) int {
+	var res int
+	for _, r := range s {
+		if r >= 0x10000 {
+			res += 2
+		} else {
+			res++
+		}
+	}
+	return res
+}
@@ -0,0 +1,187 @@
+package fbmsgr
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestGraphemeClusters(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected []string
+	}{
+		{"abc", []string{"a", "b", "c"}},
+		{"éx", []string{"é", "x"}},
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		{"👨‍👩‍👧!", []string{"👨‍👩‍👧", "!"}},
+		{"👍🏽👍", []string{"👍🏽", "👍"}},
+		{"🇺🇸🇫🇷🇩", []string{"🇺🇸", "🇫🇷", "🇩"}},
+		{"❤️x", []string{"❤️", "x"}},
+		{"🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f.",
+			[]string{"🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", "."}},
+	}
+	for _, tc := range testCases {
+		actual := graphemeClusters(tc.Input)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Errorf("%q: expected %q but got %q", tc.Input, tc.Expected, actual)
+		}
+	}
+}
+
+func TestSplitMessageBoundaries(t *testing.T) {
+	testCases := []struct {
+		Body     string
+		Max      int
+		Expected []string
+	}{
+		// Short messages are not split.
+		{"hello world", 20, []string{"hello world"}},
+
+		// Paragraphs are preferred to words.
+		{"aaaa bbbb\n\ncccc dddd eeee", 16, []string{"aaaa bbbb", "cccc dddd eeee"}},
+
+		// Lines are preferred to words.
+		{"aaaa bbbb\ncccc dddd eeee", 16, []string{"aaaa bbbb", "cccc dddd eeee"}},
+
+		// Sentences are preferred to words.
+		{"First sentence here. Second one follows", 30,
+			[]string{"First sentence here.", "Second one follows"}},
+
+		// Coarse boundaries are ignored if they would make a
+		// part too short.
+		{"a\n\nbbbb cccc dddd eeee", 16, []string{"a\n\nbbbb cccc", "dddd eeee"}},
+
+		// Words are split if there is no other choice.
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+
+		// Clusters are never split.
+		{"👨‍👩‍👧👨‍👩‍👧", 9,
+			[]string{"👨‍👩‍👧", "👨‍👩‍👧"}},
+		{"🇺🇸🇫🇷🇩🇪", 5, []string{"🇺🇸", "🇫🇷", "🇩🇪"}},
+		{"👍🏽👍🏽", 6, []string{"👍🏽", "👍🏽"}},
+	}
+	for _, tc := range testCases {
+		parts := splitMessage(&Message{Body: tc.Body}, &SplitOptions{
+			MaxLength:   tc.Max,
+			NoNumbering: true,
+		})
+		var actual []string
+		for _, part := range parts {
+			actual = append(actual, part.Body)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Errorf("%q (max %d): expected %q but got %q", tc.Body, tc.Max,
+				tc.Expected, actual)
+		}
+	}
+}
+
+func TestSplitMessageNumbering(t *testing.T) {
+	testCases := []struct {
+		Body     string
+		Max      int
+		NumParts int
+	}{
+		{strings.Repeat("word ", 8), 20, 3},
+		{strings.Repeat("word ", 36), 26, 9},
+		// Ten parts need two-digit prefixes, which leave
+		// room for fewer words, so more parts are needed.
+		{strings.Repeat("word ", 40), 26, 14},
+		{strings.Repeat("ab ", 30), 12, 30},
+		// Likewise, 112 parts need three-digit prefixes.
+		{strings.Repeat("x", 1000), 15, 200},
+	}
+	for _, tc := range testCases {
+		parts := splitMessage(&Message{Body: tc.Body}, &SplitOptions{MaxLength: tc.Max})
+		if len(parts) != tc.NumParts {
+			t.Errorf("%q (max %d): expected %d parts but got %d", tc.Body, tc.Max,
+				tc.NumParts, len(parts))
+		}
+		var joined []string
+		for i, part := range parts {
+			if utf16Len(part.Body) > tc.Max {
+				t.Errorf("part %q exceeds %d", part.Body, tc.Max)
+			}
+			prefix := "(" + strconv.Itoa(i+1) + "/" + strconv.Itoa(len(parts)) + ") "
+			if !strings.HasPrefix(part.Body, prefix) {
+				t.Errorf("part %q should start with %q", part.Body, prefix)
+			}
+			joined = append(joined, strings.TrimPrefix(part.Body, prefix))
+		}
+		expected := strings.Join(strings.Fields(tc.Body), " ")
+		actual := strings.Join(strings.Fields(strings.Join(joined, " ")), " ")
+		if !strings.Contains(tc.Body, " ") {
+			actual = strings.Join(joined, "")
+			expected = tc.Body
+		}
+		if actual != expected {
+			t.Errorf("%q: parts do not reproduce the body: %q", tc.Body, joined)
+		}
+	}
+}
+
+func TestSplitMessageMentions(t *testing.T) {
+	body := "hey 👋 @Alice Smith, how are you doing today? @Bob"
+	mentions := []Mention{
+		mentionOf(body, "@Alice Smith", "1"),
+		mentionOf(body, "@Bob", "2"),
+	}
+	for _, numbering := range []bool{false, true} {
+		for max := 20; max < 50; max++ {
+			parts := splitMessage(&Message{Body: body, Mentions: mentions}, &SplitOptions{
+				MaxLength:   max,
+				NoNumbering: !numbering,
+			})
+			var found []string
+			for _, part := range parts {
+				units := utf16.Encode([]rune(part.Body))
+				for _, m := range part.Mentions {
+					if m.Offset < 0 || m.Offset+m.Length > len(units) {
+						t.Fatalf("max %d: mention out of range in %q", max, part.Body)
+					}
+					text := string(utf16.Decode(units[m.Offset : m.Offset+m.Length]))
+					found = append(found, m.FBID+":"+text)
+				}
+			}
+			expected := []string{"1:@Alice Smith", "2:@Bob"}
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("max %d, numbering %v: expected %q but got %q", max, numbering,
+					expected, found)
+			}
+		}
+	}
+}
+
+func TestSplitMessageFirstPartExtras(t *testing.T) {
+	msg := &Message{
+		Body:         strings.Repeat("word ", 10),
+		StickerID:    123,
+		ReplyTo:      "mid.1",
+		Tags:         []string{"tag"},
+		EphemeralTTL: EphemeralOneMinute,
+	}
+	parts := splitMessage(msg, &SplitOptions{MaxLength: 20})
+	if len(parts) < 2 {
+		t.Fatal("expected multiple parts")
+	}
+	for i, part := range parts {
+		if (part.StickerID != 0) != (i == 0) || (part.ReplyTo != "") != (i == 0) {
+			t.Errorf("part %d: unexpected sticker or reply", i)
+		}
+		if len(part.Tags) != 1 || part.EphemeralTTL != EphemeralOneMinute {
+			t.Errorf("part %d: missing tags or TTL", i)
+		}
+	}
+}
+
+func mentionOf(body, text, fbid string) Mention {
+	idx := strings.Index(body, text)
+	return Mention{
+		FBID:   fbid,
+		Offset: utf16Len(body[:idx]),
+		Length: utf16Len(text),
+	}
+}
@@ -0,0 +1,42 @@
+package fbmsgr
+
+import (
+	"strconv"
+
+	"github.com/unixpickle/essentials"
+)
+
+const stickerPackDocID = "1594536353932512"
+
+// StickerPack lists the stickers in a sticker pack.
+//
+// The packID can be found in the PackID field of a
+// StickerAttachment.
+func (s *Session) StickerPack(packID int64) (stickers []*StickerAttachment, err error) {
+	defer essentials.AddCtxTo("fbmsgr: sticker pack", &err)
+
+	var response struct {
+		Node struct {
+			Stickers struct {
+				Nodes []map[string]interface{} `json:"nodes"`
+			} `json:"stickers"`
+		} `json:"node"`
+	}
+	params := map[string]interface{}{
+		"id": strconv.FormatInt(packID, 10),
+	}
+	if err := s.graphQLDoc(stickerPackDocID, params, &response); err != nil {
+		return nil, err
+	}
+	for _, node := range response.Node.Stickers.Nodes {
+		sticker, err := decodeThreadStickerAttachment(node)
+		if err != nil {
+			return nil, err
+		}
+		if sticker.PackID == 0 {
+			sticker.PackID = packID
+		}
+		stickers = append(stickers, sticker)
+	}
+	return
+}
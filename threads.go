@@ -240,6 +240,50 @@ func (s *Session) DeleteMessage(id string) (err error) {
 	return err
 }
 
+// An UnsendError is returned by UnsendMessage when the
+// server refuses to unsend a message, for example because
+// the message is too old or was not sent by the current
+// user.
+type UnsendError struct {
+	Code        int
+	Summary     string
+	Description string
+}
+
+// Error returns the server's description of the error.
+func (u *UnsendError) Error() string {
+	if u.Description != "" {
+		return "cannot unsend message: " + u.Description
+	}
+	return "cannot unsend message: " + u.Summary
+}
+
+// UnsendMessage removes a message for everybody in the
+// thread.
+//
+// Unlike DeleteMessage, this only works for messages that
+// were recently sent by the current user.
+// If the server refuses to unsend the message, the
+// underlying error is an *UnsendError.
+func (s *Session) UnsendMessage(id string) (err error) {
+	defer essentials.AddCtxTo("fbmsgr: unsend message", &err)
+
+	url := BaseURL + "/messaging/unsend_message/?dpr=1"
+	values, err := s.commonParams()
+	if err != nil {
+		return err
+	}
+	values.Set("message_id", id)
+	response, err := s.jsonForPost(url, values)
+	if err != nil {
+		return err
+	}
+	if code, summary, desc := ajaxError(response); code != 0 {
+		return &UnsendError{Code: code, Summary: summary, Description: desc}
+	}
+	return nil
+}
+
 type threadInfoResult struct {
 	ThreadKey struct {
 		ThreadFBID  *string `json:"thread_fbid"`
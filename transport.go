@@ -0,0 +1,282 @@
+package fbmsgr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const pollErrTimeout = time.Second * 5
+
+// maxBackfillAttempts is the number of times a stream
+// tries to backfill missed messages before giving up.
+const maxBackfillAttempts = 3
+
+// A Transport is a mechanism for receiving raw events
+// from Messenger's servers.
+//
+// The same Transport may be used by multiple
+// EventStreams concurrently.
+type Transport interface {
+	// Run receives events for the session and passes them
+	// to the sink until ctx is done or an unrecoverable
+	// error occurs.
+	//
+	// Run should return nil if ctx is done.
+	Run(ctx context.Context, s *Session, sink TransportSink) error
+}
+
+// A TransportSink consumes the events produced by a
+// Transport.
+type TransportSink interface {
+	// Messages handles a batch of raw messages.
+	//
+	// Messages are in the format used by the long-polling
+	// endpoint: each one has a "type" field, such as
+	// "delta", "typ", "ttyp", or "buddylist_overlay".
+	Messages(msgs []map[string]interface{})
+
+	// Connected indicates that the transport is ready to
+	// receive events, so that events which occur after
+	// this call will be passed to the sink.
+	//
+	// It may be called more than once, e.g. after every
+	// reconnect.
+	Connected()
+
+	// Interrupted indicates that the transport may have
+	// missed some messages, e.g. due to a network error.
+	Interrupted()
+}
+
+// streamSink is a TransportSink which dispatches messages
+// to an EventStream.
+type streamSink struct {
+	stream      *EventStream
+	missedSince time.Time
+
+	backfillAttempts int
+	nextBackfill     time.Time
+}
+
+func (s *streamSink) Messages(msgs []map[string]interface{}) {
+	if !s.missedSince.IsZero() && !time.Now().Before(s.nextBackfill) {
+		s.tryBackfill()
+	}
+	s.stream.dispatchMessages(msgs)
+}
+
+// Connected backfills missed messages right away, since
+// a transport may not call Messages again until a new
+// event arrives.
+func (s *streamSink) Connected() {
+	s.stream.markConnected()
+	if !s.missedSince.IsZero() {
+		s.tryBackfill()
+	}
+}
+
+// tryBackfill attempts to fetch the messages which were
+// missed since s.missedSince.
+//
+// Failed attempts are retried before later batches, with
+// an increasing delay, until maxBackfillAttempts is
+// reached and a BackfillFailedEvent is emitted.
+func (s *streamSink) tryBackfill() {
+	if !s.stream.options.Backfill {
+		s.resetBackfill()
+		return
+	}
+	err := s.stream.backfill(s.missedSince)
+	if err == nil {
+		s.resetBackfill()
+		return
+	}
+	s.backfillAttempts++
+	if s.backfillAttempts >= maxBackfillAttempts {
+		s.stream.emitEvent(BackfillFailedEvent{
+			Since: s.missedSince,
+			Error: err.Error(),
+		})
+		s.resetBackfill()
+		return
+	}
+	s.nextBackfill = time.Now().Add(pollErrTimeout * time.Duration(s.backfillAttempts))
+}
+
+func (s *streamSink) resetBackfill() {
+	s.missedSince = time.Time{}
+	s.backfillAttempts = 0
+	s.nextBackfill = time.Time{}
+}
+
+func (s *streamSink) Interrupted() {
+	if s.missedSince.IsZero() {
+		s.missedSince = s.stream.lastSeen
+	}
+}
+
+// A LongPollTransport is a Transport which receives
+// events by repeatedly polling Messenger's /pull endpoint.
+type LongPollTransport struct{}
+
+// Run polls for events until ctx is done.
+func (l *LongPollTransport) Run(ctx context.Context, s *Session, sink TransportSink) error {
+	host, err := s.callReconnect()
+	if err != nil {
+		return errors.New("reconnect: " + err.Error())
+	}
+	pool, token, err := s.fetchPollingInfo(host)
+	if err != nil {
+		return err
+	}
+	sink.Connected()
+
+	var seq int
+	startTime := time.Now().Unix()
+	for ctx.Err() == nil {
+		values := url.Values{}
+		values.Set("cap", "8")
+		values.Set("cb", "anuk")
+		values.Set("channel", "p_"+s.userID)
+		values.Set("clientid", "3342de8f")
+		values.Set("idle", strconv.FormatInt(time.Now().Unix()-startTime, 10))
+		values.Set("isq", "243")
+		values.Set("msgr_region", "FRC")
+		values.Set("msgs_recv", strconv.Itoa(seq))
+		values.Set("partition", "-2")
+		values.Set("pws", "fresh")
+		values.Set("qp", "y")
+		values.Set("seq", strconv.Itoa(seq))
+		values.Set("state", "offline")
+		values.Set("uid", s.userID)
+		values.Set("viewer_uid", s.userID)
+		values.Set("sticky_pool", pool)
+		values.Set("sticky_token", token)
+		u := "https://0-edge-chat.messenger.com/pull?" + values.Encode()
+		response, err := s.jsonForGetContext(ctx, u)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			sink.Interrupted()
+			sleepContext(ctx, pollErrTimeout)
+			continue
+		}
+		msgs, newSeq, err := parseMessages(response)
+		if newSeq > 0 {
+			seq = newSeq
+		}
+		if err != nil {
+			sink.Interrupted()
+			sleepContext(ctx, pollErrTimeout)
+			continue
+		}
+		sink.Messages(msgs)
+	}
+	return nil
+}
+
+func (s *Session) fetchPollingInfo(host string) (stickyPool, stickyToken string, err error) {
+	values := url.Values{}
+	values.Set("cap", "8")
+
+	cbStr := ""
+	s.randLock.Lock()
+	for i := 0; i < 4; i++ {
+		cbStr += string(byte(s.randGen.Intn(26)) + 'a')
+	}
+	s.randLock.Unlock()
+
+	values.Set("cb", cbStr)
+	values.Set("channel", "p_"+s.userID)
+	values.Set("clientid", "3342de8f")
+	values.Set("idle", "0")
+	values.Set("msgr_region", "FRC")
+	values.Set("msgs_recv", "0")
+	values.Set("partition", "-2")
+	values.Set("pws", "fresh")
+	values.Set("qp", "y")
+	values.Set("seq", "0")
+	values.Set("state", "offline")
+	values.Set("uid", s.userID)
+	values.Set("viewer_uid", s.userID)
+	u := "https://0-" + host + ".messenger.com/pull?" + values.Encode()
+	response, err := s.jsonForGet(u)
+	if err != nil {
+		return "", "", err
+	}
+	var respObj struct {
+		Type   string `json:"t"`
+		LbInfo *struct {
+			Sticky string `json:"sticky"`
+			Pool   string `json:"pool"`
+		} `json:"lb_info"`
+	}
+	if err := json.Unmarshal(response, &respObj); err != nil {
+		return "", "", errors.New("parse init JSON: " + err.Error())
+	}
+	if respObj.Type == "lb" && respObj.LbInfo != nil {
+		return respObj.LbInfo.Pool, respObj.LbInfo.Sticky, nil
+	}
+	return "", "", errors.New("unexpected initial polling response")
+}
+
+func (s *Session) callReconnect() (host string, err error) {
+	values, err := s.commonParams()
+	if err != nil {
+		return "", err
+	}
+	values.Set("reason", "6")
+	u := "https://www.messenger.com/ajax/presence/reconnect.php?" + values.Encode()
+	response, err := s.jsonForGet(u)
+	if err != nil {
+		return "", err
+	}
+
+	var respObj struct {
+		Payload struct {
+			Host string `json:"host"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(response, &respObj); err != nil {
+		return "", err
+	}
+	return respObj.Payload.Host, nil
+}
+
+// parseMessages extracts all of the "msg" payloads from a
+// polled event body.
+func parseMessages(data []byte) (list []map[string]interface{}, newSeq int, err error) {
+	reader := json.NewDecoder(bytes.NewBuffer(data))
+	for reader.More() {
+		var objVal struct {
+			Type     string                   `json:"t"`
+			Seq      int                      `json:"seq"`
+			Messages []map[string]interface{} `json:"ms"`
+		}
+		if err := reader.Decode(&objVal); err != nil {
+			return nil, 0, err
+		}
+		if objVal.Seq > newSeq {
+			newSeq = objVal.Seq
+		}
+		if objVal.Type == "msg" {
+			list = append(list, objVal.Messages...)
+		}
+	}
+	return
+}
+
+// sleepContext sleeps for the given duration, returning
+// early if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,296 @@
+package fbmsgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/unixpickle/essentials"
+)
+
+// MaxUploadSize is the largest file, in bytes, that
+// Messenger accepts as an attachment.
+const MaxUploadSize = 25 * 1024 * 1024
+
+// sniffLength is the number of bytes used to detect the
+// type of an upload.
+const sniffLength = 512
+
+// ErrUploadTooLarge is returned when a file exceeds
+// MaxUploadSize.
+var ErrUploadTooLarge = errors.New("upload exceeds maximum size")
+
+// preferredExtensions maps common content types to the
+// extensions used to name uploads which lack one.
+var preferredExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"video/avi":       ".avi",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"audio/ogg":       ".ogg",
+	"application/ogg": ".ogg",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// extensionTypes maps extensions of common attachments to
+// their content types, for systems whose MIME tables do
+// not include them.
+var extensionTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".doc":  "application/msword",
+	".xls":  "application/vnd.ms-excel",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".apk":  "application/vnd.android.package-archive",
+	".epub": "application/epub+zip",
+	".zip":  "application/zip",
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+	".txt":  "text/plain",
+	".csv":  "text/csv",
+}
+
+// UploadOptions stores optional settings for an upload.
+type UploadOptions struct {
+	// Progress, if non-nil, is called as the file is sent
+	// with the number of bytes sent so far.
+	// The total is the size of the file, or -1 if it is
+	// not known in advance.
+	Progress func(sent, total int64)
+}
+
+// Upload uploads a file to be sent as an attachment.
+//
+// The file's type is determined by the filename's
+// extension.
+// If the extension is missing or unknown, the type is
+// detected from the file's contents instead.
+func (s *Session) Upload(filename string, file io.Reader) (res *UploadResult, err error) {
+	defer essentials.AddCtxTo("fbmsgr: upload", &err)
+	return s.upload(filename, file, nil)
+}
+
+// UploadWithOptions is like Upload, but with extra
+// options.
+// If opts is nil, the default options are used.
+func (s *Session) UploadWithOptions(filename string, file io.Reader,
+	opts *UploadOptions) (res *UploadResult, err error) {
+	defer essentials.AddCtxTo("fbmsgr: upload", &err)
+	return s.upload(filename, file, opts)
+}
+
+// UploadFile uploads the file at the given path to be
+// sent as an attachment.
+func (s *Session) UploadFile(filePath string) (res *UploadResult, err error) {
+	defer essentials.AddCtxTo("fbmsgr: upload file", &err)
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.upload(filePath, f, nil)
+}
+
+func (s *Session) upload(filename string, file io.Reader,
+	opts *UploadOptions) (*UploadResult, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+	total := readerSize(file)
+	if total > MaxUploadSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType, ext := uploadType(filename, head)
+
+	values, err := s.commonParams()
+	if err != nil {
+		return nil, err
+	}
+	values.Set("dpr", "1")
+
+	reader, writer := io.Pipe()
+	mp := multipart.NewWriter(writer)
+
+	url := BaseURL + "/ajax/mercury/upload.php?" + values.Encode()
+	req, err := http.NewRequest("POST", url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mp.FormDataContentType())
+
+	errChan := make(chan error, 1)
+	go func() {
+		var err error
+		defer func() {
+			if err == nil {
+				err = mp.Close()
+			}
+			if err != nil {
+				errChan <- err
+			}
+			close(errChan)
+			writer.CloseWithError(err)
+		}()
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", "form-data; name=\"upload_1000\"; filename=\"file"+
+			ext+"\"")
+		header.Set("Content-Type", contentType)
+		sender, err := mp.CreatePart(header)
+		if err != nil {
+			return
+		}
+		progress := &progressWriter{
+			Writer:   sender,
+			Total:    total,
+			Callback: opts.Progress,
+		}
+		fullFile := io.MultiReader(bytes.NewReader(head), file)
+		var copied int64
+		copied, err = io.Copy(progress, io.LimitReader(fullFile, MaxUploadSize+1))
+		if err == nil && copied > MaxUploadSize {
+			err = ErrUploadTooLarge
+		}
+	}()
+
+	body, err := jsonForResp(s.Client.Do(req))
+
+	// Unblock the writer in case the server stopped reading.
+	reader.Close()
+	if writeErr := <-errChan; writeErr != nil && writeErr != io.ErrClosedPipe {
+		return nil, writeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var msg struct {
+		Payload struct {
+			Meta []struct {
+				VideoID float64 `json:"video_id"`
+				FileID  float64 `json:"file_id"`
+				AudioID float64 `json:"audio_id"`
+				ImageID float64 `json:"image_id"`
+			} `json:"metadata"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	if len(msg.Payload.Meta) != 1 {
+		return nil, errors.New("unexpected result")
+	}
+	return &UploadResult{
+		VideoID: floatIDToString(msg.Payload.Meta[0].VideoID),
+		AudioID: floatIDToString(msg.Payload.Meta[0].AudioID),
+		ImageID: floatIDToString(msg.Payload.Meta[0].ImageID),
+		FileID:  floatIDToString(msg.Payload.Meta[0].FileID),
+	}, nil
+}
+
+// uploadType determines the content type of an upload and
+// the extension to give it.
+//
+// A known extension is always kept, since many formats
+// (such as .docx and .svg) are containers that sniffing
+// would misidentify.
+// Otherwise, the type is sniffed from the contents and a
+// matching extension is chosen.
+func uploadType(filename string, head []byte) (contentType, ext string) {
+	ext = path.Ext(filename)
+	if extType := extensionType(ext); extType != "" {
+		return extType, ext
+	}
+	contentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	if ext != "" && contentType == "application/octet-stream" {
+		// Sniffing found nothing better than the caller's
+		// unknown extension.
+		return
+	}
+	ext = preferredExtensions[contentType]
+	if ext == "" {
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	return
+}
+
+// extensionType finds the content type for an extension,
+// or returns "" if it is unknown.
+func extensionType(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	if extType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil {
+		return extType
+	}
+	return extensionTypes[strings.ToLower(ext)]
+}
+
+// readerSize finds the number of bytes remaining in a
+// reader, or returns -1 if it cannot be determined.
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface {
+		Len() int
+	}:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// progressWriter reports the number of bytes written to
+// an underlying writer.
+type progressWriter struct {
+	Writer   io.Writer
+	Total    int64
+	Callback func(sent, total int64)
+
+	sent int64
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	n, err := p.Writer.Write(data)
+	p.sent += int64(n)
+	if p.Callback != nil && n > 0 {
+		p.Callback(p.sent, p.Total)
+	}
+	return n, err
+}
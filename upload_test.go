@@ -0,0 +1,48 @@
+package fbmsgr
+
+import "testing"
+
+func TestUploadType(t *testing.T) {
+	zipHead := "PK\x03\x04\x14\x00\x06\x00\x08\x00\x00\x00!\x00"
+	pngHead := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	m4aHead := "\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00M4A mp42isom\x00\x00\x00\x00"
+	svgHead := `<?xml version="1.0" encoding="UTF-8"?><svg xmlns="http://www.w3.org/2000/svg"/>`
+
+	testCases := []struct {
+		Filename string
+		Head     string
+		Type     string
+		Ext      string
+	}{
+		// Known extensions are kept, even if the contents
+		// look like something else.
+		{"report.docx", zipHead,
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			".docx"},
+		{"sheet.XLSX", zipHead,
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".XLSX"},
+		{"book.epub", zipHead, "application/epub+zip", ".epub"},
+		{"logo.svg", svgHead, "image/svg+xml", ".svg"},
+		{"voice.m4a", m4aHead, "audio/mp4", ".m4a"},
+		{"archive.zip", zipHead, "application/zip", ".zip"},
+		{"notes.txt", "hello", "text/plain", ".txt"},
+
+		// Missing or unknown extensions are replaced based
+		// on the contents.
+		{"photo", pngHead, "image/png", ".png"},
+		{"/tmp/photo", pngHead, "image/png", ".png"},
+		{"photo.unknownext", pngHead, "image/png", ".png"},
+		{"archive", zipHead, "application/zip", ".zip"},
+		{"notes", "hello", "text/plain", ".txt"},
+
+		// An unknown extension is better than nothing.
+		{"data.unknownext", "\x00\x01\x02\x03", "application/octet-stream", ".unknownext"},
+	}
+	for _, tc := range testCases {
+		contentType, ext := uploadType(tc.Filename, []byte(tc.Head))
+		if contentType != tc.Type || ext != tc.Ext {
+			t.Errorf("%s: expected (%s, %s) but got (%s, %s)", tc.Filename, tc.Type, tc.Ext,
+				contentType, ext)
+		}
+	}
+}
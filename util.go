@@ -121,6 +121,48 @@ func (s *Session) graphQLDoc(docID string, params map[string]interface{},
 	return nil
 }
 
+// graphQLMutation runs a GraphQL mutation with a
+// "doc_id".
+//
+// If the mutation is successful and dataOut is non-nil,
+// the resulting data is unmarshalled into dataOut.
+func (s *Session) graphQLMutation(docID string, variables map[string]interface{},
+	dataOut interface{}) error {
+	reqParams, err := s.commonParams()
+	if err != nil {
+		return err
+	}
+	varsJSON, err := json.Marshal(variables)
+	if err != nil {
+		return err
+	}
+	reqParams.Set("doc_id", docID)
+	reqParams.Set("variables", string(varsJSON))
+
+	response, err := s.jsonForPost(BaseURL+"/webgraphql/mutation/?dpr=1", reqParams)
+	if err != nil {
+		return err
+	}
+	var respObj struct {
+		Data   interface{} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		ErrorSummary     string `json:"errorSummary"`
+		ErrorDescription string `json:"errorDescription"`
+	}
+	respObj.Data = dataOut
+	if err := json.Unmarshal(response, &respObj); err != nil {
+		return err
+	}
+	if len(respObj.Errors) > 0 {
+		return errors.New("GraphQL error: " + respObj.Errors[0].Message)
+	} else if respObj.ErrorSummary != "" {
+		return errors.New("GraphQL error: " + respObj.ErrorSummary)
+	}
+	return nil
+}
+
 // jsonForPost posts the form and returns the raw JSON
 // from the response.
 func (s *Session) jsonForPost(url string, params url.Values) ([]byte, error) {
@@ -161,6 +203,21 @@ func jsonForResp(resp *http.Response, err error) ([]byte, error) {
 	return body[9:], nil
 }
 
+// ajaxError extracts the error from the JSON response of
+// an AJAX endpoint.
+// If there is no error, code is 0.
+func ajaxError(response []byte) (code int, summary, description string) {
+	var respObj struct {
+		Error            int    `json:"error"`
+		ErrorSummary     string `json:"errorSummary"`
+		ErrorDescription string `json:"errorDescription"`
+	}
+	if json.Unmarshal(response, &respObj) != nil {
+		return 0, "", ""
+	}
+	return respObj.Error, respObj.ErrorSummary, respObj.ErrorDescription
+}
+
 // putJSONIntoObject turns source into JSON, then
 // unmarshals it back into the destination.
 func putJSONIntoObject(source, dest interface{}) error {
@@ -202,3 +259,23 @@ func canonicalFBID(val interface{}) string {
 	}
 	return ""
 }
+
+// parseMillisTimestamp converts a millisecond timestamp,
+// encoded either as a string or as a float64, into a time.
+// It returns the zero time if the timestamp is invalid.
+func parseMillisTimestamp(val interface{}) time.Time {
+	var millis int64
+	switch val := val.(type) {
+	case string:
+		parsed, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return time.Time{}
+		}
+		millis = parsed
+	case float64:
+		millis = int64(val)
+	default:
+		return time.Time{}
+	}
+	return time.Unix(millis/1000, (millis%1000)*1000000)
+}
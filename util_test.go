@@ -0,0 +1,44 @@
+package fbmsgr
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const testTimeout = time.Second * 10
+
+// testSession creates a Session whose HTTP requests are
+// all sent to a local server.
+func testSession(t *testing.T, handler http.Handler) *Session {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Session{
+		Client: &http.Client{
+			Transport: redirectTransport{serverURL},
+		},
+		userID:     "1234",
+		fbDTSG:     "dtsg",
+		fbDTSGTime: time.Now(),
+		randGen:    rand.New(rand.NewSource(1)),
+		sentIDs:    newLRUSet(sentIDsCapacity),
+		closed:     make(chan struct{}),
+	}
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}